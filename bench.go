@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench [tool] [-- tool args]",
+	Short: "Measure a tool's throughput at several worker counts",
+	Long: `Runs the same sample of the input once per worker count and reports the throughput of each run,
+recommending the smallest worker count that comes close to the best one. Results are only counted,
+not written to a file.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runBench,
+}
+
+var (
+	benchWorkers string
+	benchSample  int
+)
+
+// benchTolerance is how close to the best throughput a worker count must be to be recommended:
+// beyond that point more workers mostly add load on the target and the machine
+const benchTolerance = 0.9
+
+// benchResult is the outcome of one benchmark run
+type benchResult struct {
+	Workers  int
+	Duration time.Duration
+	Results  int64
+	Failed   int
+}
+
+func (b benchResult) linesPerSecond(lines int) float64 {
+	return float64(lines) / b.Duration.Seconds()
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	benchCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input file path (leave empty to read from stdin)")
+	benchCmd.Flags().StringVar(&benchWorkers, "workers", "1,2,4,8,16", "Comma-separated worker counts to compare")
+	benchCmd.Flags().IntVar(&benchSample, "sample", 500, "Number of input lines to run at each worker count; 0 uses the whole input")
+	benchCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to custom config file")
+	benchCmd.Flags().StringVar(&profile, "profile", "", "Config profile whose [profiles.<name>.tools] override the default tools (default: $BULKER_PROFILE)")
+	benchCmd.Flags().StringVarP(&wordlist, "wordlist", "w", "", "Path to wordlist file (for tools like ffuf)")
+	benchCmd.Flags().StringVar(&shellName, "shell", "", "Shell used to run tool commands (default: $BULKER_SHELL, then bash or sh; cmd on Windows)")
+}
+
+func runBench(cmd *cobra.Command, args []string) {
+	tool := strings.ToLower(args[0])
+
+	counts, err := parseWorkerCounts(benchWorkers)
+	if err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+
+	configManager, err := NewConfigManager(configFile, profile)
+	if err != nil {
+		LogError("Error loading config file: %v", err)
+		os.Exit(1)
+	}
+	toolConfig, exists := configManager.GetToolConfig(tool)
+	if !exists {
+		LogError("Error: tool '%s' not found in config file", tool)
+		os.Exit(1)
+	}
+	if toolConfig.MaxWorkers > 0 {
+		var allowed []int
+		for _, count := range counts {
+			if count > toolConfig.MaxWorkers {
+				LogWarn("Skipping %d workers: tool '%s' is limited to %d", count, tool, toolConfig.MaxWorkers)
+				continue
+			}
+			allowed = append(allowed, count)
+		}
+		counts = allowed
+		if len(counts) == 0 {
+			LogError("Error: no worker count within the tool's max_workers of %d", toolConfig.MaxWorkers)
+			os.Exit(1)
+		}
+	}
+
+	shell, err := resolveShell(shellName)
+	if err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+
+	// Every run gets the same lines, so the sample is read once (stdin cannot be read again)
+	tempDir, err := os.MkdirTemp("", "bulker_bench_")
+	if err != nil {
+		LogError("Error creating temp directory: %v", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(tempDir)
+
+	samplePath := filepath.Join(tempDir, "sample.txt")
+	lines, err := writeBenchSample(inputFile, samplePath, benchSample)
+	if err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+	if lines == 0 {
+		LogError("Error: the input has no lines to benchmark with")
+		os.Exit(1)
+	}
+	LogInfo("Benchmarking %s on %d input lines at %s workers", tool, lines, benchWorkers)
+
+	var results []benchResult
+	for _, count := range counts {
+		LogInfo("Running with %d workers...", count)
+		result, interrupted, err := runBenchStage(tool, args[1:], samplePath, shell, count)
+		if err != nil {
+			LogError("Error: %v", err)
+			os.Exit(1)
+		}
+		if interrupted {
+			LogWarn("Benchmark interrupted; results so far:")
+			break
+		}
+		results = append(results, result)
+	}
+
+	printBenchResults(results, lines)
+}
+
+// parseWorkerCounts parses a comma-separated list of worker counts, sorted and without duplicates
+func parseWorkerCounts(value string) ([]int, error) {
+	seen := make(map[int]bool)
+	var counts []int
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		count, err := strconv.Atoi(field)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid worker count '%s' in --workers", field)
+		}
+		if !seen[count] {
+			seen[count] = true
+			counts = append(counts, count)
+		}
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("--workers needs at least one worker count")
+	}
+	sort.Ints(counts)
+	return counts, nil
+}
+
+// writeBenchSample copies the first limit non-empty lines of input (stdin if empty) to path,
+// or every line if limit is 0, and returns how many were written
+func writeBenchSample(input, path string, limit int) (int, error) {
+	var reader io.Reader = os.Stdin
+	if input != "" {
+		file, err := os.Open(input)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	sample, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create sample file: %w", err)
+	}
+	defer sample.Close()
+
+	writer := bufio.NewWriter(sample)
+	scanner := newLineScanner(reader, 0)
+	lines := 0
+	for scanner.Scan() && (limit == 0 || lines < limit) {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		fmt.Fprintln(writer, scanner.Text())
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("error reading input: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write sample file: %w", err)
+	}
+	return lines, nil
+}
+
+// runBenchStage runs the tool on the sample with the given number of workers. The run's own logs
+// are silenced so only the benchmark table is shown; it reports whether the run was interrupted.
+func runBenchStage(tool string, toolArgs []string, sample, shell string, workers int) (benchResult, bool, error) {
+	runner, err := NewRunner(RunnerConfig{
+		InputFile:       sample,
+		Workers:         workers,
+		Command:         tool,
+		CommandArgs:     toolArgs,
+		ConfigFile:      configFile,
+		Profile:         profile,
+		Wordlist:        wordlist,
+		Scheduler:       "static",
+		Shell:           shell,
+		AppendNewline:   true,
+		StripANSI:       true,
+		CountOnly:       true,
+		CollectErrors:   true,
+		ShutdownTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return benchResult{}, false, err
+	}
+
+	SetConsoleLogging(false)
+	start := time.Now()
+	err = runner.Run()
+	elapsed := time.Since(start)
+	SetConsoleLogging(true)
+	if err != nil {
+		return benchResult{}, false, err
+	}
+
+	duration := runner.taskSpan()
+	if duration <= 0 {
+		duration = elapsed
+	}
+	result := benchResult{
+		Workers:  workers,
+		Duration: duration,
+		Results:  runner.resultCount.Load(),
+		Failed:   runner.FailedCount(),
+	}
+	return result, runner.unfinishedCount() > 0, nil
+}
+
+// taskSpan is the time from the first task start to the last task end. Unlike the length of Run
+// it leaves out input reading and the once-a-second completion check, which would otherwise
+// round short benchmark runs up to whole seconds.
+func (r *Runner) taskSpan() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var first, last time.Time
+	for _, task := range r.tasks {
+		if !task.StartTime.IsZero() && (first.IsZero() || task.StartTime.Before(first)) {
+			first = task.StartTime
+		}
+		if task.EndTime.After(last) {
+			last = task.EndTime
+		}
+	}
+	if first.IsZero() || last.IsZero() {
+		return 0
+	}
+	return last.Sub(first)
+}
+
+// printBenchResults prints one row per worker count and recommends the smallest count whose
+// throughput is within benchTolerance of the best run without failed tasks
+func printBenchResults(results []benchResult, lines int) {
+	if len(results) == 0 {
+		return
+	}
+
+	best := 0.0
+	for _, result := range results {
+		if result.Failed == 0 {
+			best = max(best, result.linesPerSecond(lines))
+		}
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "WORKERS\tTIME\tLINES/S\tRESULTS\tFAILED")
+	for _, result := range results {
+		fmt.Fprintf(writer, "%d\t%s\t%.1f\t%d\t%d\n", result.Workers, result.Duration.Round(time.Millisecond), result.linesPerSecond(lines), result.Results, result.Failed)
+	}
+	writer.Flush()
+
+	if best == 0 {
+		LogWarn("Every run had failed tasks; no worker count recommended")
+		return
+	}
+	for _, result := range results {
+		if result.Failed == 0 && result.linesPerSecond(lines) >= best*benchTolerance {
+			LogSuccess("Recommended: -t %d (%.1f lines/s, best %.1f)", result.Workers, result.linesPerSecond(lines), best)
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var chainCmd = &cobra.Command{
+	Use:   "chain [tool1] [tool2] ...",
+	Short: "Run several tools back-to-back, feeding each tool's output to the next",
+	Long: `Runs the given tools sequentially on the same input. The output of each stage becomes the
+input of the next, and the output of the last stage is written to --output. The chain stops at the
+first stage that fails or produces no output.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runChain,
+}
+
+func init() {
+	rootCmd.AddCommand(chainCmd)
+
+	chainCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input file path for the first tool (leave empty to read from stdin)")
+	chainCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file path for the last tool (required)")
+	chainCmd.Flags().IntVarP(&workers, "threads", "t", 4, "Number of parallel threads for each stage")
+	chainCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to custom config file")
+	chainCmd.Flags().StringVar(&profile, "profile", "", "Config profile whose [profiles.<name>.tools] override the default tools (default: $BULKER_PROFILE)")
+	chainCmd.Flags().StringVarP(&wordlist, "wordlist", "w", "", "Path to wordlist file (for tools like ffuf)")
+	chainCmd.Flags().StringVar(&shellName, "shell", "", "Shell used to run tool commands (default: $BULKER_SHELL, then bash or sh; cmd on Windows)")
+}
+
+func runChain(cmd *cobra.Command, args []string) {
+	if outputFile == "" {
+		LogError("Error: --output flag is required when running a chain")
+		cmd.Help()
+		os.Exit(1)
+	}
+
+	configManager, err := NewConfigManager(configFile, profile)
+	if err != nil {
+		LogError("Error loading config file: %v", err)
+		os.Exit(1)
+	}
+	for _, tool := range args {
+		if _, exists := configManager.GetToolConfig(tool); !exists {
+			LogError("Error: tool '%s' not found in config file", tool)
+			os.Exit(1)
+		}
+	}
+
+	shell, err := resolveShell(shellName)
+	if err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+
+	finalOutput := resolveOutputTemplate(outputFile, args[len(args)-1], time.Now())
+
+	// Intermediate stage outputs live in a temp dir that is removed when the chain ends
+	tempDir, err := os.MkdirTemp("", "bulker_chain_")
+	if err != nil {
+		LogError("Error creating temp directory: %v", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(tempDir)
+
+	stageInput := inputFile
+	for i, tool := range args {
+		stageOutput := finalOutput
+		if i < len(args)-1 {
+			stageOutput = filepath.Join(tempDir, fmt.Sprintf("stage_%d_%s.txt", i, tool))
+		}
+
+		LogInfo("Chain stage %d/%d: %s", i+1, len(args), tool)
+		if err := runChainStage(tool, stageInput, stageOutput, shell, i == len(args)-1); err != nil {
+			LogError("Chain stopped at stage %d (%s): %v", i+1, tool, err)
+			os.Exit(1)
+		}
+
+		stageInput = stageOutput
+	}
+
+	LogSuccess("Chain completed. Final output written to: %s", finalOutput)
+}
+
+// runChainStage runs one tool of a chain and returns an error if any task failed or,
+// for intermediate stages, if there is nothing to pass on
+func runChainStage(tool, input, output, shell string, last bool) error {
+	runner, err := NewRunner(RunnerConfig{
+		InputFile:     input,
+		OutputFile:    output,
+		Workers:       workers,
+		Command:       tool,
+		ConfigFile:    configFile,
+		Profile:       profile,
+		Wordlist:      wordlist,
+		Scheduler:     "static",
+		Shell:         shell,
+		AppendNewline: true,
+		LineEnding:    LineEndingLF,
+		StripANSI:     true,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := runner.Run(); err != nil {
+		return err
+	}
+	if failed := runner.FailedCount(); failed > 0 {
+		return fmt.Errorf("%d tasks failed", failed)
+	}
+
+	if !last {
+		info, err := os.Stat(output)
+		if err != nil {
+			return fmt.Errorf("stage produced no output: %w", err)
+		}
+		if info.Size() == 0 {
+			return fmt.Errorf("stage produced no output")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// sortRunMaxBytes bounds how much line data is held in memory while building one sorted run
+// during an external merge sort.
+const sortRunMaxBytes = 64 * 1024 * 1024
+
+// ResultCollector merges result files found in a directory into a single output file
+type ResultCollector struct {
+	resultDir  string
+	pattern    string
+	outputFile string
+}
+
+// NewResultCollector creates a collector for files in resultDir matching pattern
+func NewResultCollector(resultDir, pattern, outputFile string) *ResultCollector {
+	if pattern == "" {
+		pattern = "*.txt"
+	}
+	return &ResultCollector{
+		resultDir:  resultDir,
+		pattern:    pattern,
+		outputFile: outputFile,
+	}
+}
+
+// findResultFiles returns matching result files sorted by name, excluding the output file itself
+func (rc *ResultCollector) findResultFiles() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(rc.resultDir, rc.pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", rc.pattern, err)
+	}
+
+	outputAbs, _ := filepath.Abs(rc.outputFile)
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if matchAbs, _ := filepath.Abs(match); matchAbs == outputAbs {
+			continue
+		}
+		files = append(files, match)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// FileStat describes a single result file
+type FileStat struct {
+	Name    string
+	Size    int64
+	Lines   int
+	ModTime time.Time
+}
+
+// MergeStats summarises the result files a collector would merge
+type MergeStats struct {
+	ResultFiles int
+	TotalSize   int64
+	TotalLines  int
+	Files       []FileStat
+}
+
+// GetMergeStats returns typed statistics for all matching result files
+func (rc *ResultCollector) GetMergeStats() (MergeStats, error) {
+	files, err := rc.findResultFiles()
+	if err != nil {
+		return MergeStats{}, err
+	}
+
+	stats := MergeStats{Files: make([]FileStat, 0, len(files))}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return MergeStats{}, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+
+		lines := 0
+		if err := forEachLine(path, false, func(string) error {
+			lines++
+			return nil
+		}); err != nil {
+			return MergeStats{}, err
+		}
+
+		stats.Files = append(stats.Files, FileStat{
+			Name:    filepath.Base(path),
+			Size:    info.Size(),
+			Lines:   lines,
+			ModTime: info.ModTime(),
+		})
+		stats.ResultFiles++
+		stats.TotalSize += info.Size()
+		stats.TotalLines += lines
+	}
+
+	return stats, nil
+}
+
+// GetStats returns result file statistics as a generic map.
+// Prefer GetMergeStats for typed access.
+func (rc *ResultCollector) GetStats() (map[string]interface{}, error) {
+	stats, err := rc.GetMergeStats()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result_files": stats.ResultFiles,
+		"total_size":   stats.TotalSize,
+		"total_lines":  stats.TotalLines,
+	}, nil
+}
+
+// MergeResults concatenates all result files into the output file
+func (rc *ResultCollector) MergeResults() error {
+	files, err := rc.findResultFiles()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(rc.outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer out.Close()
+
+	writer := bufio.NewWriter(out)
+	for _, path := range files {
+		if err := forEachLine(path, false, func(line string) error {
+			_, err := writer.WriteString(line + "\n")
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	LogInfo("Merged %d result files into %s", len(files), rc.outputFile)
+	return nil
+}
+
+// MergeResultsCommand pipes the lines of all result files through a shell command (e.g. "sort -u")
+// and writes its stdout to the output file. The output is written to a temp file first and only
+// replaces the output file if the command succeeds, so a failing command leaves it untouched.
+func (rc *ResultCollector) MergeResultsCommand(command, shell string) error {
+	files, err := rc.findResultFiles()
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.Create(rc.outputFile + ".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	cmd := exec.Command(shell, shellCommandFlag(shell), command)
+	cmd.Stdout = temp
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start merge command: %w", err)
+	}
+
+	// Feed the command while it runs; a command that exits early (head) closes the pipe, which
+	// only ends the feeding
+	fed := make(chan error, 1)
+	go func() {
+		writer := bufio.NewWriter(stdin)
+		var err error
+		for _, path := range files {
+			if err = forEachLine(path, false, func(line string) error {
+				_, err := writer.WriteString(line + "\n")
+				return err
+			}); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = writer.Flush()
+		}
+		stdin.Close()
+		fed <- err
+	}()
+
+	waitErr := cmd.Wait()
+	feedErr := <-fed
+	if waitErr != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("merge command failed: %w: %s", waitErr, msg)
+		}
+		return fmt.Errorf("merge command failed: %w", waitErr)
+	}
+	if feedErr != nil && !errors.Is(feedErr, syscall.EPIPE) && !errors.Is(feedErr, os.ErrClosed) {
+		return feedErr
+	}
+
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(temp.Name(), rc.outputFile); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	LogInfo("Merged %d result files into %s through %q", len(files), rc.outputFile, command)
+	return nil
+}
+
+// MergeResultsSorted writes the lines of all result files to the output file in sorted order,
+// dropping duplicate lines when dedup is true. Input is sorted in bounded-size runs spilled to
+// temporary files and then k-way merged, so result sets larger than memory are supported.
+func (rc *ResultCollector) MergeResultsSorted(dedup bool) error {
+	files, err := rc.findResultFiles()
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "bulker_merge_")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	runs, err := rc.writeSortedRuns(files, tempDir)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(rc.outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer out.Close()
+
+	written, err := mergeSortedRuns(runs, out, dedup)
+	if err != nil {
+		return err
+	}
+
+	LogInfo("Merged %d result files into %s (%d lines, %d sorted runs)", len(files), rc.outputFile, written, len(runs))
+	return nil
+}
+
+// writeSortedRuns reads all lines from files and writes them as sorted run files in tempDir
+func (rc *ResultCollector) writeSortedRuns(files []string, tempDir string) ([]string, error) {
+	var runs []string
+	var lines []string
+	var size int
+
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		sort.Strings(lines)
+		runPath := filepath.Join(tempDir, fmt.Sprintf("run_%d.txt", len(runs)))
+		if err := writeLines(runPath, lines); err != nil {
+			return err
+		}
+		runs = append(runs, runPath)
+		lines = lines[:0]
+		size = 0
+		return nil
+	}
+
+	for _, path := range files {
+		if err := forEachLine(path, true, func(line string) error {
+			lines = append(lines, line)
+			size += len(line)
+			if size >= sortRunMaxBytes {
+				return flush()
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// forEachLine calls fn for every line of the file at path. Blank lines are skipped only when
+// skipBlank is set, for merges that sort or de-duplicate, where they would all end up together.
+func forEachLine(path string, skipBlank bool, fn func(line string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := newLineScanner(file, 0)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if skipBlank && line == "" {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeLines writes lines to path, one per line
+func writeLines(path string, lines []string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, line := range lines {
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return writer.Flush()
+}
+
+// runCursor is the current head line of one sorted run during a k-way merge
+type runCursor struct {
+	line    string
+	scanner *bufio.Scanner
+}
+
+type runHeap []*runCursor
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].line < h[j].line }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runCursor)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// mergeSortedRuns k-way merges sorted run files into w and returns the number of lines written
+func mergeSortedRuns(runs []string, w io.Writer, dedup bool) (int, error) {
+	h := &runHeap{}
+	for _, runPath := range runs {
+		file, err := os.Open(runPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open sorted run: %w", err)
+		}
+		defer file.Close()
+
+		scanner := newLineScanner(file, 0)
+		if scanner.Scan() {
+			*h = append(*h, &runCursor{line: scanner.Text(), scanner: scanner})
+		} else if err := scanner.Err(); err != nil {
+			return 0, fmt.Errorf("error reading sorted run: %w", err)
+		}
+	}
+	heap.Init(h)
+
+	writer := bufio.NewWriter(w)
+	written := 0
+	var previous string
+	for h.Len() > 0 {
+		cursor := (*h)[0]
+		line := cursor.line
+
+		if !dedup || written == 0 || line != previous {
+			if _, err := writer.WriteString(line + "\n"); err != nil {
+				return written, fmt.Errorf("failed to write merged output: %w", err)
+			}
+			previous = line
+			written++
+		}
+
+		if cursor.scanner.Scan() {
+			cursor.line = cursor.scanner.Text()
+			heap.Fix(h, 0)
+		} else {
+			if err := cursor.scanner.Err(); err != nil {
+				return written, fmt.Errorf("error reading sorted run: %w", err)
+			}
+			heap.Pop(h)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return written, fmt.Errorf("failed to write merged output: %w", err)
+	}
+	return written, nil
+}
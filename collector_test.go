@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeKeepsBlankLinesUnlessSorted(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("b\n\na\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		merge func(rc *ResultCollector) error
+		want  string
+	}{
+		{"plain", (*ResultCollector).MergeResults, "b\n\na\n\nb\n"},
+		{"sorted", func(rc *ResultCollector) error { return rc.MergeResultsSorted(false) }, "a\nb\nb\n"},
+		{"unique", func(rc *ResultCollector) error { return rc.MergeResultsSorted(true) }, "a\nb\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "merged.txt")
+			if err := tt.merge(NewResultCollector(dir, "*.txt", output)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("merged %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
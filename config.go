@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -18,15 +19,178 @@ type ToolConfig struct {
 	Command           string   `toml:"command"`
 	AutoOptimizations []string `toml:"auto_optimizations"`
 	Header            string   `toml:"header"`
+	// Distribution controls how input lines are assigned to chunks in multiple mode.
+	// "block" (default) gives each task a contiguous range; "round_robin" sends line i to task i%workers.
+	Distribution string `toml:"distribution"`
 	// UseStdout specifies whether the tool writes its main output to stdout instead of (or in addition to) the file given by -o/redirect.
 	// When true Bulker will capture stdout and stream it to the final output file rather than expecting to read the temporary file.
-	UseStdout bool     `toml:"use_stdout"`
-	Examples  []string `toml:"examples"`
+	UseStdout bool `toml:"use_stdout"`
+	// CaptureStderr also writes the tool's stderr lines to the output file, for tools that print their
+	// results there. By default stderr is only logged.
+	CaptureStderr bool `toml:"capture_stderr"`
+	// CombineOutput sends stdout and stderr through one pipe to the output file, preserving the
+	// order in which the tool interleaved them. Cannot be used with capture_stderr.
+	CombineOutput bool `toml:"combine_output"`
+	// CollapseOutput writes each single-mode task's output as one record tagged with its input line:
+	// "input<CollapseDelimiter>result1<CollapseSeparator>result2" (tab and "|" by default). Empty
+	// lines are dropped and a task with no output writes no record. The header is written as-is.
+	CollapseOutput    bool   `toml:"collapse_output"`
+	CollapseDelimiter string `toml:"collapse_delimiter"`
+	CollapseSeparator string `toml:"collapse_separator"`
+	// Persistent starts one long-lived process per worker and streams each task's input lines to
+	// its stdin instead of starting a process per task. Results are read from stdout, so the
+	// command must not use {input} or {output}. Suited to tools that read targets from stdin
+	// (httpx, dnsx); a process that dies is restarted.
+	Persistent bool `toml:"persistent"`
+	// SplitWordlist parallelizes over the wordlist instead of the input: the wordlist is split into
+	// chunks and each input line (the target) is run once per chunk. Requires {wordlist} in Command.
+	SplitWordlist bool `toml:"split_wordlist"`
+	// MaxWorkers caps the number of parallel workers for this tool regardless of -t (0 means no cap)
+	MaxWorkers int `toml:"max_workers"`
+	// Retries is how many times a task may be re-run; it is the shared budget for retry features.
+	Retries int `toml:"retries"`
+	// RetryIfEmpty re-runs a task that exits successfully but produces no output, up to Retries times.
+	RetryIfEmpty bool `toml:"retry_if_empty"`
+	// RetryExitCodes re-runs a task that exits with one of these codes (e.g. a tool's rate-limit
+	// status), up to Retries times. Any other failure fails the task right away.
+	RetryExitCodes []int `toml:"retry_exit_codes"`
+	// Env sets extra environment variables for the tool. Values may use {task_id} and {task_name}
+	// to differ per task, e.g. for proxy or credential rotation.
+	Env map[string]string `toml:"env"`
+	// CommentPrefix marks input lines to skip, e.g. "# note" in an annotated target list (default "#").
+	CommentPrefix string `toml:"comment_prefix"`
+	// InputPattern is a regular expression every input line must match, e.g. "^[a-z0-9.-]+$" for
+	// hostnames. It is checked on the lines the tool would get (after input_format extraction and
+	// preprocess, ignoring surrounding spaces); other lines are skipped, or stop the run with --strict.
+	InputPattern string `toml:"input_pattern"`
+	// Preprocess is a shell command that transforms the input before tasks are created, e.g.
+	// "sed -E 's/:[0-9]+$//'". It reads the input lines (after comments are removed) on stdin and
+	// its stdout becomes the input; with --input-dir it runs once per file. With PreprocessPerLine
+	// it runs once per line instead, with {input} replaced by the line. A failure stops the run.
+	Preprocess        string `toml:"preprocess"`
+	PreprocessPerLine bool   `toml:"preprocess_per_line"`
+	// Parser names a built-in output parser (e.g. "url", "httpx-json") that normalizes each output
+	// line into a {url, status, length} record, written in ParserFormat ("json" or "tsv").
+	Parser       string `toml:"parser"`
+	ParserFormat string `toml:"parser_format"`
+	// DirectExec runs the tool without a shell wrapper. Each word of the command template becomes
+	// one argument, so substituted input is passed verbatim even if it contains shell metacharacters.
+	// Shell features such as pipes and redirects (> {output}) are not available in this mode.
+	DirectExec bool `toml:"direct_exec"`
+	// NoGlob stops the shell from expanding * and ? in the command, so literal asterisks in
+	// arguments (fuzzing payloads, {args}) reach the tool unchanged. Not needed with DirectExec.
+	NoGlob bool `toml:"no_glob"`
+	// InputMode says how the task's input reaches the tool: "placeholder" (default) substitutes
+	// {input} in the command; "arg" appends it as the last argument; "file_flag" appends InputFlag
+	// followed by it (e.g. "-l chunk.txt"); "stdin" writes the task's input lines to the tool's
+	// stdin. In multiple mode the input is the chunk file, otherwise the line itself. Modes other
+	// than "placeholder" do not allow {input} in the command.
+	InputMode string `toml:"input_mode"`
+	InputFlag string `toml:"input_flag"`
+	// DefaultPort is the {port} of single-mode input lines without a port, when a URL scheme does
+	// not imply one (default 80). See splitHostPort.
+	DefaultPort string `toml:"default_port"`
+	// Container, when set, is a Docker image each task runs in (docker run --rm), with the working
+	// directory mounted so {input} and {output} work unchanged. The command runs under sh in the
+	// image, or as the entrypoint with DirectExec. The image is pulled before the first task.
+	Container string `toml:"container"`
+	// VersionCommand prints the tool's version (e.g. "httpx -version"); when MinVersion is set,
+	// bulker runs it at startup and warns, or fails with --strict, if the tool is older.
+	VersionCommand string   `toml:"version_command"`
+	MinVersion     string   `toml:"min_version"`
+	Examples       []string `toml:"examples"`
+}
+
+// Input modes for ToolConfig.InputMode
+const (
+	InputModePlaceholder = "placeholder"
+	InputModeArg         = "arg"
+	InputModeStdin       = "stdin"
+	InputModeFileFlag    = "file_flag"
+)
+
+// validateInputMode checks that the tool's input_mode and input_flag fit together and with its command
+func validateInputMode(name string, toolConfig ToolConfig) error {
+	switch toolConfig.InputMode {
+	case "", InputModePlaceholder:
+		return nil
+	case InputModeArg, InputModeStdin:
+	case InputModeFileFlag:
+		if toolConfig.InputFlag == "" {
+			return fmt.Errorf("tool '%s' uses input_mode = \"file_flag\" but has no input_flag", name)
+		}
+	default:
+		return fmt.Errorf("invalid input_mode '%s' for tool '%s' (use placeholder, arg, stdin or file_flag)", toolConfig.InputMode, name)
+	}
+	if strings.Contains(toolConfig.Command, "{input}") {
+		return fmt.Errorf("tool '%s' uses input_mode = \"%s\", so its command cannot use {input}", name, toolConfig.InputMode)
+	}
+	return nil
+}
+
+// commandPlaceholders are the placeholders BuildCommand substitutes in a tool's command
+var commandPlaceholders = map[string]bool{
+	"input": true, "output": true, "args": true, "wordlist": true, "auto_optimizations": true,
+	"host": true, "port": true, "input_file": true, "input_first": true,
+}
+
+// placeholderPattern matches {name} tokens in a command. Shell braces such as ${VAR}, {a,b} or
+// awk's {print $1} are not placeholders: the first is skipped by checkPlaceholders, the others
+// do not match. Braces inside quotes, such as jq '{name}' or awk '{print}', are not checked.
+var placeholderPattern = regexp.MustCompile(`\{([a-z_][a-z0-9_]*)\}`)
+
+// checkPlaceholders reports {name} tokens in the tool's command that BuildCommand does not
+// substitute, such as a misspelled {ouput}, which would otherwise reach the shell as they are.
+// The template is checked rather than the built command, whose input and args may hold braces.
+// Known placeholders are still substituted everywhere, quoted or not.
+func checkPlaceholders(name, command string) error {
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatchIndex(blankQuoted(command), -1) {
+		if match[0] > 0 && command[match[0]-1] == '$' {
+			continue
+		}
+		placeholder := command[match[0]:match[1]]
+		if commandPlaceholders[command[match[2]:match[3]]] || seen[placeholder] {
+			continue
+		}
+		seen[placeholder] = true
+		unknown = append(unknown, placeholder)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("tool '%s' has unresolved placeholders in its command: %s (known: {input}, {output}, {args}, {wordlist}, {auto_optimizations}, {host}, {port}, {input_file}, {input_first})", name, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// blankQuoted returns command with the text inside single and double quotes replaced by spaces,
+// keeping every other byte at its offset. Quoted text belongs to the program it is passed to.
+func blankQuoted(command string) string {
+	blanked := []byte(command)
+	quote := byte(0)
+	for i := 0; i < len(blanked); i++ {
+		switch c := blanked[i]; {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			blanked[i] = ' '
+		case c == '\\':
+			i++ // An escaped quote does not start a quoted string
+		case c == '\'' || c == '"':
+			quote = c
+		}
+	}
+	return string(blanked)
 }
 
 // Config holds all tool configurations
 type Config struct {
 	Tools map[string]ToolConfig `toml:"tools"`
+	// Profiles holds per-profile tool overrides, e.g. [profiles.prod.tools.httpx]. They are
+	// decoded on top of the matching [tools] entry once a profile is selected.
+	Profiles map[string]struct {
+		Tools map[string]toml.Primitive `toml:"tools"`
+	} `toml:"profiles"`
 }
 
 // ConfigManager manages tool configurations
@@ -67,8 +231,9 @@ func findConfigFile(customPath string) (string, error) {
 	return "", fmt.Errorf("config file not found in current directory or home directory")
 }
 
-// NewConfigManager creates a new config manager
-func NewConfigManager(configPath string) (*ConfigManager, error) {
+// NewConfigManager creates a new config manager. profile selects a [profiles.<name>] section
+// whose tools override the default [tools]; when empty, BULKER_PROFILE is used if set.
+func NewConfigManager(configPath string, profile string) (*ConfigManager, error) {
 	// Find the actual config file path
 	actualConfigPath, err := findConfigFile(configPath)
 	if err != nil {
@@ -83,13 +248,115 @@ func NewConfigManager(configPath string) (*ConfigManager, error) {
 
 	// Parse TOML
 	var config Config
-	if _, err := toml.Decode(string(data), &config); err != nil {
+	meta, err := toml.Decode(string(data), &config)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// GetToolConfig looks tools up by lowercase name, so store them that way
+	names := make([]string, 0, len(config.Tools))
+	for name := range config.Tools {
+		names = append(names, name)
+	}
+	if err := checkToolNames(names, "[tools]"); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if lower := strings.ToLower(name); lower != name {
+			config.Tools[lower] = config.Tools[name]
+			delete(config.Tools, name)
+		}
+	}
+
+	if profile == "" {
+		profile = os.Getenv("BULKER_PROFILE")
+	}
+	if profile != "" {
+		if err := applyProfile(&config, meta, profile); err != nil {
+			return nil, err
+		}
+	}
+
 	return &ConfigManager{config: config}, nil
 }
 
+// rawToolName is the tool name used for a command template given with --raw
+const rawToolName = "raw"
+
+// newRawToolConfig builds the tool for a --raw command template run in mode ("single" or
+// "multiple"). The template must use {input}; without {output} the tool's stdout is taken as its output.
+func newRawToolConfig(template, mode string) (ToolConfig, error) {
+	if !strings.Contains(template, "{input}") {
+		return ToolConfig{}, fmt.Errorf("--raw command must contain {input}")
+	}
+	return ToolConfig{
+		Name:        rawToolName,
+		Description: "Command given with --raw",
+		Mode:        mode,
+		Command:     template,
+		UseStdout:   !strings.Contains(template, "{output}"),
+	}, nil
+}
+
+// NewRawConfigManager creates a config manager holding only the given tool, for runs that
+// don't use a config file
+func NewRawConfigManager(tool ToolConfig) *ConfigManager {
+	return &ConfigManager{config: Config{Tools: map[string]ToolConfig{strings.ToLower(tool.Name): tool}}}
+}
+
+// applyProfile overlays the tools of the named profile onto config.Tools. Fields a profile
+// sets replace those of the default tool; tools only defined in the profile are added.
+func applyProfile(config *Config, meta toml.MetaData, profile string) error {
+	section, exists := config.Profiles[profile]
+	if !exists {
+		return fmt.Errorf("profile '%s' not found in config file", profile)
+	}
+
+	if config.Tools == nil {
+		config.Tools = make(map[string]ToolConfig)
+	}
+	names := make([]string, 0, len(section.Tools))
+	for name := range section.Tools {
+		names = append(names, name)
+	}
+	if err := checkToolNames(names, fmt.Sprintf("profile '%s'", profile)); err != nil {
+		return err
+	}
+	for rawName, primitive := range section.Tools {
+		name := strings.ToLower(rawName)
+		tool := config.Tools[name]
+		// Copy the env table so the profile does not write into the default tool's map
+		if tool.Env != nil {
+			env := make(map[string]string, len(tool.Env))
+			for key, value := range tool.Env {
+				env[key] = value
+			}
+			tool.Env = env
+		}
+		if err := meta.PrimitiveDecode(primitive, &tool); err != nil {
+			return fmt.Errorf("failed to parse profile '%s' tool '%s': %w", profile, name, err)
+		}
+		config.Tools[name] = tool
+	}
+	return nil
+}
+
+// checkToolNames reports names that differ only in case (e.g. "httpx" and "HTTPX"). Tools are
+// looked up case-insensitively, so one would silently shadow the other; where says which config
+// section they came from.
+func checkToolNames(names []string, where string) error {
+	sort.Strings(names)
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if other, exists := seen[key]; exists {
+			return fmt.Errorf("%s defines tool '%s' twice (as '%s' and '%s'); tool names are case-insensitive", where, key, other, name)
+		}
+		seen[key] = name
+	}
+	return nil
+}
+
 // GetToolConfig returns configuration for a tool
 func (cm *ConfigManager) GetToolConfig(toolName string) (ToolConfig, bool) {
 	config, exists := cm.config.Tools[strings.ToLower(toolName)]
@@ -117,25 +384,105 @@ func (cm *ConfigManager) GetAllTools() []ToolConfig {
 	return tools
 }
 
-// BuildCommand builds the command for a tool based on config
-func (cm *ConfigManager) BuildCommand(toolName, inputData string, args []string, tempOutputFile string, wordlist string) ([]string, error) {
+// BuildCommand builds the command for a tool based on config.
+// Values substituted for {input}, {output} and {wordlist} are quoted for the given shell so that
+// an input line such as "; rm -rf ~" reaches the tool as a literal argument. Each of args is
+// quoted too, so a value already split out of -e '-H "Cookie: a=b"' stays one argument.
+// {auto_optimizations} is inserted as-is since it is written as shell words in the config.
+func (cm *ConfigManager) BuildCommand(toolName, inputData, inputFirst string, args []string, tempOutputFile string, wordlist string, shell string) ([]string, error) {
 	toolConfig, exists := cm.GetToolConfig(toolName)
 	if !exists {
 		return nil, fmt.Errorf("tool %s not found in config", toolName)
 	}
 
+	// {host} and {port} are parsed from the input line; NewRunner only allows them in single mode
+	var host, port string
+	if usesHostPort(toolConfig.Command) {
+		var err error
+		if host, port, err = splitHostPort(inputData, toolConfig.DefaultPort); err != nil {
+			return nil, err
+		}
+	}
+
+	if toolConfig.DirectExec {
+		return buildDirectCommand(toolConfig, inputData, inputFirst, args, tempOutputFile, wordlist, host, port), nil
+	}
+
 	// Build auto optimizations string
 	autoOptimizations := strings.Join(toolConfig.AutoOptimizations, " ")
-	argsString := strings.Join(args, " ")
-
-	// Replace placeholders in command
-	command := toolConfig.Command
-	command = strings.ReplaceAll(command, "{input}", inputData)
-	command = strings.ReplaceAll(command, "{auto_optimizations}", autoOptimizations)
-	command = strings.ReplaceAll(command, "{args}", argsString)
-	command = strings.ReplaceAll(command, "{output}", tempOutputFile)
-	command = strings.ReplaceAll(command, "{wordlist}", wordlist)
-
-	// Split command into parts for execution
-	return strings.Fields(command), nil
+	quotedArgs := make([]string, len(args))
+	for i, arg := range args {
+		quotedArgs[i] = shellQuote(shell, arg)
+	}
+	argsString := strings.Join(quotedArgs, " ")
+	// An empty value leaves no argument behind, rather than an empty quoted one
+	quote := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return shellQuote(shell, value)
+	}
+
+	// Replace placeholders word by word, so quoted values are not split apart again
+	replacer := strings.NewReplacer(
+		"{input}", quote(inputData),
+		"{input_file}", quote(inputData),
+		"{input_first}", quote(inputFirst),
+		"{auto_optimizations}", autoOptimizations,
+		"{args}", argsString,
+		"{output}", quote(tempOutputFile),
+		"{wordlist}", quote(wordlist),
+		"{host}", quote(host),
+		"{port}", port,
+	)
+
+	var parts []string
+	for _, field := range strings.Fields(toolConfig.Command) {
+		if replaced := replacer.Replace(field); replaced != "" {
+			parts = append(parts, replaced)
+		}
+	}
+	return appendInputArgs(parts, toolConfig, shellQuote(shell, inputData)), nil
+}
+
+// appendInputArgs adds the input to the end of the command for the "arg" and "file_flag" input
+// modes. A shell still applies redirects such as "> {output}" that come before it.
+func appendInputArgs(parts []string, toolConfig ToolConfig, input string) []string {
+	switch toolConfig.InputMode {
+	case InputModeArg:
+		return append(parts, input)
+	case InputModeFileFlag:
+		return append(parts, toolConfig.InputFlag, input)
+	}
+	return parts
+}
+
+// buildDirectCommand builds an argument list for running a tool without a shell.
+// The template is split into words before placeholders are substituted, so a value
+// containing spaces or metacharacters stays a single argument.
+func buildDirectCommand(toolConfig ToolConfig, inputData, inputFirst string, args []string, tempOutputFile string, wordlist string, host, port string) []string {
+	replacer := strings.NewReplacer(
+		"{input}", inputData,
+		"{input_file}", inputData,
+		"{input_first}", inputFirst,
+		"{output}", tempOutputFile,
+		"{wordlist}", wordlist,
+		"{host}", host,
+		"{port}", port,
+	)
+
+	var parts []string
+	for _, field := range strings.Fields(toolConfig.Command) {
+		switch field {
+		case "{args}":
+			parts = append(parts, args...)
+		case "{auto_optimizations}":
+			for _, optimization := range toolConfig.AutoOptimizations {
+				parts = append(parts, splitArgsRespectingQuotes(optimization)...)
+			}
+		default:
+			parts = append(parts, replacer.Replace(field))
+		}
+	}
+	return appendInputArgs(parts, toolConfig, inputData)
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		unknown string // Expected in the error; empty when the command is valid
+	}{
+		{"known placeholders", "tool -i {input} -o {output} {args} -w {wordlist}", ""},
+		{"host and port", "nc {host} {port}", ""},
+		{"misspelled output", "tool -i {input} -o {ouput}", "{ouput}"},
+		{"bogus placeholder", "tool {input} {target}", "{target}"},
+		{"reported once", "tool {x} {x} {input}", "{x}"},
+		{"shell variable", "tool {input} -H ${TOKEN}", ""},
+		{"brace expansion", "cp {input} out.{a,b}", ""},
+		{"awk program", "awk '{print $1}' {input}", ""},
+		{"awk print quoted", "awk '{print}' {input} > {output}", ""},
+		{"jq object", "jq '{host: .h}' {input}", ""},
+		{"jq shorthand", `jq -c "{name}" {input}`, ""},
+		{"escaped quote", `tool \'{bogus}`, "{bogus}"},
+		{"unquoted after quoted", "jq '{name}' {input} {ouput}", "{ouput}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPlaceholders("tool", tt.command)
+			switch {
+			case tt.unknown == "" && err != nil:
+				t.Errorf("checkPlaceholders(%q) = %v, want no error", tt.command, err)
+			case tt.unknown != "" && err == nil:
+				t.Errorf("checkPlaceholders(%q) found nothing, want %s", tt.command, tt.unknown)
+			case tt.unknown != "" && !strings.Contains(err.Error(), tt.unknown):
+				t.Errorf("checkPlaceholders(%q) = %v, want %s", tt.command, err, tt.unknown)
+			}
+		})
+	}
+}
+
+func TestBuildCommandLeavesJQObjectsAlone(t *testing.T) {
+	cm := &ConfigManager{config: Config{Tools: map[string]ToolConfig{
+		"jq": {Name: "jq", Mode: "single", UseStdout: true, Command: "echo {input} | jq -c '{host: .h}'"},
+	}}}
+
+	got, err := cm.BuildCommand("jq", `{"h":"x"}`, "", nil, "", "", "bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command := strings.Join(got, " "); !strings.Contains(command, "'{host: .h}'") {
+		t.Errorf("BuildCommand = %s, want the jq program unchanged", command)
+	}
+}
+
+func TestBuildCommandSubstitutesQuotedKnownPlaceholders(t *testing.T) {
+	// A known name is a placeholder even inside quotes; jq's {host} shorthand needs {host: .host}
+	cm := &ConfigManager{config: Config{Tools: map[string]ToolConfig{
+		"jq": {Name: "jq", Mode: "single", UseStdout: true, Command: "jq -n '{host}' --arg x {input}"},
+	}}}
+
+	got, err := cm.BuildCommand("jq", "http://example.com:8080", "", nil, "", "", "bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command := strings.Join(got, " "); !strings.Contains(command, "'example.com'") {
+		t.Errorf("BuildCommand = %s, want {host} substituted", command)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// containerWorkDir is where the working directory, with the task's chunk and temp output files,
+// is mounted inside a tool's container
+const containerWorkDir = "/work"
+
+// containerShell runs tool commands inside containers; many tool images have no bash
+const containerShell = "sh"
+
+// validateContainer rejects tool settings that cannot work when tasks run in a container
+func validateContainer(name string, toolConfig ToolConfig, remote string) error {
+	switch {
+	case remote != "":
+		return fmt.Errorf("tool '%s' runs in a container, which cannot be combined with --remote", name)
+	case toolConfig.Persistent:
+		return fmt.Errorf("persistent tool '%s' cannot run in a container", name)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("tool '%s' runs in container %s, but docker is not installed", name, toolConfig.Container)
+	}
+	return nil
+}
+
+// prepareContainerImage pulls the tool's image once before any task starts, so a missing image
+// fails the run up front instead of every task pulling it at the same time
+func (r *Runner) prepareContainerImage() error {
+	image := r.toolConfig.Container
+	if exec.Command("docker", "image", "inspect", image).Run() == nil {
+		return nil
+	}
+
+	LogInfo("Pulling container image %s", image)
+	pull := exec.Command("docker", "pull", "--quiet", image)
+	if output, err := pull.CombinedOutput(); err != nil {
+		return fmt.Errorf("container image %s not found: %s", image, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// containerName names a task's container, so it can be removed when the task is stopped
+func (r *Runner) containerName(task *Task) string {
+	return fmt.Sprintf("bulker_%s_%d", r.runID, task.ID)
+}
+
+// newContainerCommand runs a task's command in a fresh container of the tool's image. The working
+// directory is mounted at containerWorkDir and used as the container's working directory, so the
+// relative chunk and temp output names in the command work unchanged; the wordlist is mounted
+// read-only at its own absolute path. Files are created as the current user where it has a uid.
+func (r *Runner) newContainerCommand(cmdParts []string, task *Task) (*exec.Cmd, error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	args := []string{"run", "--rm", "-i", "--name", r.containerName(task),
+		"-v", workDir + ":" + containerWorkDir, "-w", containerWorkDir}
+	if r.config.Wordlist != "" && !r.toolConfig.SplitWordlist {
+		if wordlist, err := filepath.Abs(r.config.Wordlist); err == nil {
+			args = append(args, "-v", wordlist+":"+wordlist+":ro")
+		}
+	}
+	if uid := os.Getuid(); uid >= 0 {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", uid, os.Getgid()))
+	}
+	for _, entry := range r.taskEnv(task) {
+		args = append(args, "-e", entry)
+	}
+
+	if r.toolConfig.DirectExec {
+		if len(cmdParts) == 0 {
+			return nil, fmt.Errorf("empty command")
+		}
+		args = append(args, "--entrypoint", cmdParts[0], r.toolConfig.Container)
+		args = append(args, cmdParts[1:]...)
+	} else {
+		command := strings.Join(cmdParts, " ")
+		if r.toolConfig.NoGlob || r.config.NoGlob {
+			command = withoutGlobbing(containerShell, command)
+		}
+		args = append(args, "--entrypoint", containerShell, r.toolConfig.Container, "-c", command)
+	}
+
+	LogInfo("Running command in container %s: %q", r.toolConfig.Container, cmdParts)
+	return exec.Command("docker", args...), nil
+}
+
+// removeContainer force-removes a stopped task's container. Stopping the docker client does not
+// always stop the container it started.
+func (r *Runner) removeContainer(task *Task) {
+	exec.Command("docker", "rm", "-f", r.containerName(task)).Run()
+}
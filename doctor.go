@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that bulker and its tools are set up correctly",
+	Long:  `Verifies the config file, tool binaries on PATH, the shell used to run tools and write access to the output directory.`,
+	Run:   runDoctor,
+}
+
+var doctorOutputDir string
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to custom config file")
+	doctorCmd.Flags().StringVar(&profile, "profile", "", "Config profile whose [profiles.<name>.tools] override the default tools (default: $BULKER_PROFILE)")
+	doctorCmd.Flags().StringVar(&shellName, "shell", "", "Shell to check (default: $BULKER_SHELL, then bash or sh; cmd on Windows)")
+	doctorCmd.Flags().StringVarP(&doctorOutputDir, "output-dir", "o", ".", "Directory that output files will be written to")
+}
+
+type checkStatus int
+
+const (
+	checkPass checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+// doctorReport prints checklist lines and remembers whether anything failed
+type doctorReport struct {
+	failed bool
+}
+
+func (d *doctorReport) add(status checkStatus, format string, args ...interface{}) {
+	var label, color string
+	switch status {
+	case checkPass:
+		label, color = "PASS", Green
+	case checkWarn:
+		label, color = "WARN", Yellow
+	case checkFail:
+		label, color = "FAIL", Red
+		d.failed = true
+	}
+	fmt.Printf("  [%s%s%s] %s\n", paint(color), label, paint(Reset), fmt.Sprintf(format, args...))
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	report := &doctorReport{}
+
+	fmt.Println("Config:")
+	configPath, err := findConfigFile(configFile)
+	var configManager *ConfigManager
+	if err != nil {
+		report.add(checkFail, "config file: %v", err)
+	} else if configManager, err = NewConfigManager(configFile, profile); err != nil {
+		report.add(checkFail, "config file %s: %v", configPath, err)
+	} else {
+		report.add(checkPass, "config file %s loaded (%d tools)", configPath, len(configManager.GetAllTools()))
+	}
+
+	fmt.Println("\nShell:")
+	if shell, err := resolveShell(shellName); err != nil {
+		report.add(checkFail, "%v", err)
+	} else {
+		report.add(checkPass, "shell '%s' found", shell)
+	}
+
+	fmt.Println("\nTools:")
+	if configManager == nil {
+		report.add(checkWarn, "skipped, config not loaded")
+	} else {
+		for _, tool := range configManager.GetAllTools() {
+			fields := strings.Fields(tool.Command)
+			if len(fields) == 0 {
+				report.add(checkFail, "%s: empty command", tool.Name)
+				continue
+			}
+			binary := fields[0]
+			if path, err := exec.LookPath(binary); err != nil {
+				report.add(checkWarn, "%s: '%s' not found on PATH", tool.Name, binary)
+			} else {
+				report.add(checkPass, "%s: %s", tool.Name, path)
+			}
+		}
+	}
+
+	fmt.Println("\nOutput:")
+	if err := checkWritableDir(doctorOutputDir); err != nil {
+		report.add(checkFail, "output directory %s is not writable: %v", doctorOutputDir, err)
+	} else {
+		report.add(checkPass, "output directory %s is writable", doctorOutputDir)
+	}
+
+	fmt.Println("")
+	if report.failed {
+		LogError("Some checks failed")
+		os.Exit(1)
+	}
+	LogSuccess("All required checks passed")
+}
+
+// checkWritableDir verifies that a file can be created in dir
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+
+	file, err := os.CreateTemp(dir, ".bulker_doctor_")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
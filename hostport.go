@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// fallbackPort is the {port} of an input without a port, scheme or tool default_port
+const fallbackPort = "80"
+
+// schemePorts are the ports of URL schemes, for URL input without an explicit port
+var schemePorts = map[string]string{
+	"http": "80", "https": "443", "ws": "80", "wss": "443",
+	"ftp": "21", "ssh": "22", "smtp": "25", "ldap": "389", "ldaps": "636",
+}
+
+// usesHostPort reports whether a command uses {host} or {port}
+func usesHostPort(command string) bool {
+	return strings.Contains(command, "{host}") || strings.Contains(command, "{port}")
+}
+
+// splitHostPort derives {host} and {port} from an input line: "host", "host:port", "[v6]:port",
+// a bare IPv6 address, or a URL. Without a port, a URL's scheme decides it, then defaultPort
+// (the tool's default_port), then fallbackPort.
+func splitHostPort(input, defaultPort string) (string, string, error) {
+	input = strings.TrimSpace(input)
+	host, port := input, ""
+
+	if strings.Contains(input, "://") {
+		parsed, err := url.Parse(input)
+		if err != nil {
+			return "", "", fmt.Errorf("cannot get {host} and {port} from '%s': %v", input, err)
+		}
+		host, port = parsed.Hostname(), parsed.Port()
+		if port == "" {
+			port = schemePorts[strings.ToLower(parsed.Scheme)]
+		}
+	} else if h, p, err := net.SplitHostPort(input); err == nil {
+		host, port = h, p
+	} else if strings.HasPrefix(input, "[") && strings.HasSuffix(input, "]") {
+		host = input[1 : len(input)-1]
+	}
+	// Anything else, including a bare IPv6 address, is all host
+
+	if host == "" {
+		return "", "", fmt.Errorf("cannot get {host} from '%s'", input)
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	if port == "" {
+		port = fallbackPort
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return "", "", fmt.Errorf("invalid port '%s' in '%s'", port, input)
+	}
+	return host, port, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// inputScanner yields the records of one input source: lines, or whole CSV records, which span
+// several lines when a quoted field holds a newline. *bufio.Scanner is the line scanner.
+type inputScanner interface {
+	Scan() bool
+	Text() string
+	Err() error
+}
+
+// recordExtractor pulls the input value out of one record of structured input.
+// ok is false for records that hold no value (a CSV header, a record without the field).
+type recordExtractor func(record string) (value string, ok bool, err error)
+
+// checkInputFormat validates --input-format and the field or column it needs
+func checkInputFormat(format, jsonField, csvColumn string) error {
+	_, _, err := newInputScanner(strings.NewReader(""), 0, format, jsonField, csvColumn)
+	return err
+}
+
+// newInputScanner returns the scanner over one input source and the extractor for --input-format,
+// or a nil extractor for plain line input. Both are created once per input source, since a CSV
+// header applies only to its own file; a header named by csvColumn is read before returning.
+func newInputScanner(reader io.Reader, maxLineSize int, format, jsonField, csvColumn string) (inputScanner, recordExtractor, error) {
+	switch format {
+	case "", "line":
+		return newLineScanner(reader, maxLineSize), nil, nil
+	case "json":
+		if jsonField == "" {
+			return nil, nil, fmt.Errorf("--input-format json requires --json-field")
+		}
+		path := strings.Split(jsonField, ".")
+		return newLineScanner(reader, maxLineSize), func(line string) (string, bool, error) {
+			var record interface{}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				return "", false, fmt.Errorf("invalid JSON record: %w", err)
+			}
+			value, ok := lookupJSONPath(record, path)
+			return value, ok, nil
+		}, nil
+	case "csv":
+		if csvColumn == "" {
+			return nil, nil, fmt.Errorf("--input-format csv requires --csv-column")
+		}
+		// A numeric column is a 1-based index into every row; a name is looked up in the header row
+		column, err := strconv.Atoi(csvColumn)
+		byName := err != nil
+		if !byName && column < 1 {
+			return nil, nil, fmt.Errorf("invalid --csv-column %d (columns start at 1)", column)
+		}
+		column--
+		records := newCSVScanner(reader)
+		if byName {
+			// The header is read here, so the comment and --since filters only see data records
+			column, err = records.readHeader(csvColumn)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return records, func(string) (string, bool, error) {
+			fields := records.fields
+			if column >= len(fields) {
+				return "", false, nil
+			}
+			return fields[column], true, nil
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown input format '%s' (use line, json or csv)", format)
+	}
+}
+
+// csvScanner reads CSV records with a single csv.Reader over the whole stream, so quoted fields
+// may hold newlines. Text is the raw record, which the comment and --since filters look at;
+// fields holds its parsed fields for the extractor.
+type csvScanner struct {
+	reader *csv.Reader
+	raw    bytes.Buffer // Bytes read by reader that are not part of a returned record yet
+	offset int64        // Stream offset of the start of raw
+	text   string
+	fields []string
+	err    error
+}
+
+func newCSVScanner(reader io.Reader) *csvScanner {
+	s := &csvScanner{}
+	s.reader = csv.NewReader(io.TeeReader(reader, &s.raw))
+	s.reader.FieldsPerRecord = -1 // Rows may differ in length; short rows just lack the column
+	return s
+}
+
+func (s *csvScanner) Scan() bool {
+	fields, err := s.reader.Read()
+	if err != nil {
+		if err != io.EOF {
+			s.err = fmt.Errorf("invalid CSV record: %w", err)
+		}
+		return false
+	}
+	end := s.reader.InputOffset()
+	// The raw bytes include blank lines the reader skipped before the record and its line ending
+	s.text = strings.Trim(string(s.raw.Next(int(end-s.offset))), "\r\n")
+	s.offset = end
+	s.fields = fields
+	return true
+}
+
+// readHeader reads the header record and returns the index of the column called name. An empty
+// input has no header and no data, so any column will do.
+func (s *csvScanner) readHeader(name string) (int, error) {
+	if !s.Scan() {
+		return 0, s.Err()
+	}
+	for i, field := range s.fields {
+		if strings.TrimSpace(field) == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("column %q not found in CSV header", name)
+}
+
+func (s *csvScanner) Text() string { return s.text }
+
+func (s *csvScanner) Err() error { return s.err }
+
+// lookupJSONPath follows a dotted path such as "host.name" or "hosts.0" through a decoded
+// JSON value. Only strings, numbers and booleans are returned as values.
+func lookupJSONPath(value interface{}, path []string) (string, bool) {
+	for _, key := range path {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			value = node[index]
+		default:
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, v != ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
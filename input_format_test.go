@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// extractAll runs input through the scanner and extractor for format and returns the values
+func extractAll(t *testing.T, input, format, jsonField, csvColumn string) []string {
+	t.Helper()
+	scanner, extract, err := newInputScanner(strings.NewReader(input), 0, format, jsonField, csvColumn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []string
+	for scanner.Scan() {
+		value, ok, err := extract(scanner.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			values = append(values, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestCSVInputQuotedNewlines(t *testing.T) {
+	input := "name,url\n\"multi\nline\",http://a\n\nplain,http://b\r\n\"say \"\"hi\"\"\",\"http://c\"\n"
+
+	want := []string{"http://a", "http://b", "http://c"}
+	if got := extractAll(t, input, "csv", "", "url"); !reflect.DeepEqual(got, want) {
+		t.Errorf("url column = %q, want %q", got, want)
+	}
+	want = []string{"name", "multi\nline", "plain", `say "hi"`}
+	if got := extractAll(t, input, "csv", "", "1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("column 1 = %q, want %q", got, want)
+	}
+}
+
+func TestCSVScannerRawRecords(t *testing.T) {
+	s := newCSVScanner(strings.NewReader("a,b\n\n\"x\ny\",z\r\nlast,row"))
+	var records []string
+	for s.Scan() {
+		records = append(records, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a,b", "\"x\ny\",z", "last,row"}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
+func TestCSVScannerInvalidRecord(t *testing.T) {
+	s := newCSVScanner(strings.NewReader("a,b\n\"unterminated,c\n"))
+	for s.Scan() {
+	}
+	if s.Err() == nil {
+		t.Fatal("no error for an unterminated quoted field")
+	}
+}
+
+func TestCSVHeaderSkipsSinceFilter(t *testing.T) {
+	now := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	since, err := newSinceFilter("24h", `^([^,]+),`, "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{
+		config:      RunnerConfig{InputFormat: "csv", CSVColumn: "url"},
+		sinceFilter: since,
+	}
+	input := "time,url\n2024-04-01T00:00:00Z,http://old\n2024-05-01T12:00:00Z,http://new\n"
+	if err := r.appendInputLines(strings.NewReader(input), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"http://new"}; !reflect.DeepEqual(r.inputLines, want) {
+		t.Errorf("input = %q, want %q", r.inputLines, want)
+	}
+	if r.undatedCount != 0 {
+		t.Errorf("%d lines counted as undated, want 0: the header is not a record", r.undatedCount)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// workerLimiter bounds how many tasks run at once. Unlike a buffered channel its capacity can
+// change while tasks run: the limit is set by -t and live resizing, and the memory throttle can
+// hold some of it back. Lowering the capacity never stops running tasks; it only takes effect
+// as they finish. Waiters are served in order, and a freed slot wakes only the next one.
+type workerLimiter struct {
+	mu      sync.Mutex
+	limit   int             // Workers allowed, before the memory throttle
+	max     int             // Upper bound for limit; 0 means none
+	held    int             // Slots held back by the memory throttle
+	active  int             // Tasks currently running, including slots handed to waiters
+	waiters []chan struct{} // acquire calls waiting for a slot, oldest first
+	grow    func(limit int) // Called when resize raises the limit; see onGrow
+}
+
+func newWorkerLimiter(limit, maxLimit int) *workerLimiter {
+	return &workerLimiter{limit: limit, max: maxLimit}
+}
+
+// capacityLocked is the number of tasks allowed to run now, never less than one
+func (l *workerLimiter) capacityLocked() int {
+	return max(l.limit-l.held, 1)
+}
+
+// grantLocked hands free slots to waiters, oldest first
+func (l *workerLimiter) grantLocked() {
+	for len(l.waiters) > 0 && l.active < l.capacityLocked() {
+		l.active++
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+	}
+}
+
+// acquire waits for a free slot. It returns false if cancel is closed first.
+func (l *workerLimiter) acquire(cancel <-chan struct{}) bool {
+	l.mu.Lock()
+	if len(l.waiters) == 0 && l.active < l.capacityLocked() {
+		l.active++
+		l.mu.Unlock()
+		return true
+	}
+	granted := make(chan struct{})
+	l.waiters = append(l.waiters, granted)
+	l.mu.Unlock()
+
+	select {
+	case <-granted:
+		return true
+	case <-cancel:
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, waiter := range l.waiters {
+		if waiter == granted {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return false
+		}
+	}
+	// The slot was handed over just as cancel closed: pass it on
+	l.active--
+	l.grantLocked()
+	return false
+}
+
+// release frees a slot taken by acquire
+func (l *workerLimiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.grantLocked()
+	l.mu.Unlock()
+}
+
+// onGrow sets a function called with the new limit whenever resize raises it, so a pool of
+// workers sized for the old limit can grow
+func (l *workerLimiter) onGrow(grow func(limit int)) {
+	l.mu.Lock()
+	l.grow = grow
+	l.mu.Unlock()
+}
+
+// resize changes the worker limit by delta, within 1 and max, and returns the new limit
+func (l *workerLimiter) resize(delta int) int {
+	l.mu.Lock()
+	previous := l.limit
+	l.limit = max(l.limit+delta, 1)
+	if l.max > 0 {
+		l.limit = min(l.limit, l.max)
+	}
+	l.grantLocked()
+	limit, grow := l.limit, l.grow
+	l.mu.Unlock()
+
+	if grow != nil && limit > previous {
+		grow(limit)
+	}
+	return limit
+}
+
+// hold takes one slot of capacity away (for the memory throttle) unless only one would be left,
+// and returns the resulting capacity and whether a slot was taken
+func (l *workerLimiter) hold() (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit-l.held <= 1 {
+		return l.capacityLocked(), false
+	}
+	l.held++
+	return l.capacityLocked(), true
+}
+
+// unhold gives back one slot taken by hold and returns the resulting capacity
+func (l *workerLimiter) unhold() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held > 0 {
+		l.held--
+		l.grantLocked()
+	}
+	return l.capacityLocked()
+}
+
+// unholdAll gives back every slot taken by hold
+func (l *workerLimiter) unholdAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held = 0
+	l.grantLocked()
+}
+
+// watchResizeSignals changes the worker limit when a resize signal arrives (SIGUSR1 adds a worker,
+// SIGUSR2 removes one), until the returned function is called. Persistent workers are fixed
+// processes, so there the signals are only acknowledged.
+func (r *Runner) watchResizeSignals(limiter *workerLimiter) func() {
+	if len(resizeSignals) == 0 {
+		return func() {}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, resizeSignals...)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case sig := <-signals:
+				if r.toolConfig.Persistent {
+					LogWarn("Received %v, but the number of persistent workers cannot change during a run", sig)
+					continue
+				}
+				limit := limiter.resize(resizeDelta(sig))
+				LogInfo("Received %v, workers set to %d (a lower limit applies as running tasks finish)", sig, limit)
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorkerLimiterServesWaitersInOrder(t *testing.T) {
+	l := newWorkerLimiter(1, 0)
+	never := make(chan struct{})
+	if !l.acquire(never) {
+		t.Fatal("first acquire failed")
+	}
+
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			if l.acquire(never) {
+				order <- i
+			}
+		}(i)
+		// Let each waiter queue up before the next one
+		waitForWaiters(t, l, i+1)
+	}
+
+	for want := 0; want < 3; want++ {
+		l.release()
+		if got := <-order; got != want {
+			t.Fatalf("waiter %d got the slot, want %d", got, want)
+		}
+	}
+}
+
+func TestWorkerLimiterCancelledWaiterLeavesQueue(t *testing.T) {
+	l := newWorkerLimiter(1, 0)
+	never := make(chan struct{})
+	l.acquire(never)
+
+	cancel := make(chan struct{})
+	done := make(chan bool)
+	go func() { done <- l.acquire(cancel) }()
+	waitForWaiters(t, l, 1)
+	close(cancel)
+	if <-done {
+		t.Fatal("cancelled acquire returned true")
+	}
+
+	l.release()
+	if !l.acquire(never) {
+		t.Fatal("slot was not free after the cancelled waiter left")
+	}
+}
+
+func TestWorkerLimiterResizeGrows(t *testing.T) {
+	l := newWorkerLimiter(1, 3)
+	var grownTo []int
+	l.onGrow(func(limit int) { grownTo = append(grownTo, limit) })
+
+	if got := l.resize(5); got != 3 {
+		t.Fatalf("resize(5) = %d, want the max of 3", got)
+	}
+	if got := l.resize(-1); got != 2 {
+		t.Fatalf("resize(-1) = %d, want 2", got)
+	}
+	if len(grownTo) != 1 || grownTo[0] != 3 {
+		t.Fatalf("onGrow calls = %v, want [3]", grownTo)
+	}
+}
+
+func waitForWaiters(t *testing.T, l *workerLimiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		l.mu.Lock()
+		queued := len(l.waiters)
+		l.mu.Unlock()
+		if queued >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d waiters queued, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
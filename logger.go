@@ -1,9 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -20,6 +25,17 @@ const (
 	Gray   = "\033[90m"
 )
 
+// ansiEscapePattern matches ANSI CSI sequences (colors, cursor movement) and OSC sequences
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// StripANSI removes ANSI escape sequences from s
+func StripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiEscapePattern.ReplaceAllString(s, "")
+}
+
 type LogLevel int
 
 const (
@@ -36,12 +52,135 @@ type Logger struct {
 
 var logger = &Logger{level: INFO}
 
+// syslogSink is the subset of *syslog.Writer used for logging
+type syslogSink interface {
+	Debug(m string) error
+	Info(m string) error
+	Notice(m string) error
+	Warning(m string) error
+	Err(m string) error
+	Close() error
+}
+
+var (
+	logSyslog  syslogSink // Receives log messages in addition to the console when set
+	logConsole = true     // Whether log messages are written to the console
+)
+
+// EnableSyslog routes log messages to the local syslog, tagged with tag
+func EnableSyslog(tag string) error {
+	sink, err := openSyslog(tag)
+	if err != nil {
+		return err
+	}
+	logSyslog = sink
+	return nil
+}
+
+// SetConsoleLogging turns log output on stderr on or off; tool output echoed to the console is not affected
+func SetConsoleLogging(enabled bool) {
+	logConsole = enabled
+}
+
+// SetConsoleWriter sets where echoed tool output and --tail results are written (stdout by default)
+func SetConsoleWriter(w io.Writer) {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	console = w
+}
+
+// sendSyslog forwards a message to syslog with the severity matching level
+func sendSyslog(level LogLevel, message string) {
+	if logSyslog == nil {
+		return
+	}
+	switch level {
+	case DEBUG:
+		logSyslog.Debug(message)
+	case WARN:
+		logSyslog.Warning(message)
+	case ERROR:
+		logSyslog.Err(message)
+	case SUCCESS:
+		logSyslog.Notice(message)
+	default:
+		logSyslog.Info(message)
+	}
+}
+
+// console is where echoed tool output is written. Log lines go to logOutput, so stdout carries
+// nothing but results and can be piped.
+var (
+	console   io.Writer = os.Stdout
+	logOutput io.Writer = os.Stderr
+)
+
+var (
+	consoleMu          sync.Mutex
+	consoleClosed      bool // The console's reader went away; tool output is dropped from then on
+	logClosed          bool // Same for logOutput, kept apart so logs outlive a closed results pipe
+	brokenPipeHandler  func()
+	brokenPipeNotified sync.Once
+)
+
+// SetBrokenPipeHandler registers a function called once when the console reader goes away
+// (e.g. output piped into `head`), so the run can shut down instead of writing into a closed pipe.
+func SetBrokenPipeHandler(handler func()) {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	brokenPipeHandler = handler
+}
+
+// writeConsole writes tool output to the console. After a broken pipe all further writes are
+// dropped silently and the broken pipe handler is called.
+func writeConsole(s string) {
+	if writeTo(console, s, &consoleClosed) {
+		consoleMu.Lock()
+		handler := brokenPipeHandler
+		consoleMu.Unlock()
+		if handler != nil {
+			brokenPipeNotified.Do(handler)
+		}
+	}
+}
+
+// writeLog writes a log line to logOutput. A closed log pipe only drops log lines; it does not
+// stop the run, whose results may still be read.
+func writeLog(s string) {
+	writeTo(logOutput, s, &logClosed)
+}
+
+// writeTo writes s to w under the console lock, so log lines and tool output never split each
+// other. closed is w's broken pipe flag; writeTo reports whether it is set.
+func writeTo(w io.Writer, s string, closed *bool) bool {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	if *closed {
+		return true
+	}
+	if _, err := io.WriteString(w, s); isBrokenPipe(err) {
+		*closed = true
+	}
+	return *closed
+}
+
+// isBrokenPipe reports whether err means the reader of a pipe went away: EPIPE from a real pipe,
+// or io.ErrClosedPipe from an in-process io.Pipe
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// ConsolePrintln writes a line of tool output to the console
+func ConsolePrintln(line string) {
+	writeConsole(line + "\n")
+}
+
 func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	if level < l.level {
 		return
 	}
 
-	timestamp := time.Now().Format("15:04:05")
+	timestamp := logTimestamp()
 	message := fmt.Sprintf(format, args...)
 
 	var levelStr, color string
@@ -63,9 +202,14 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 		color = Green
 	}
 
+	sendSyslog(level, message)
+	if !logConsole {
+		return
+	}
+
 	// Format: [timestamp] [LEVEL] message
-	fmt.Printf("%s[%s] [%s%s%s] %s%s\n",
-		Gray, timestamp, color, levelStr, Reset, message, Reset)
+	writeLog(fmt.Sprintf("%s[%s] [%s%s%s] %s%s\n",
+		paint(Gray), timestamp, paint(color), levelStr, paint(Reset), message, paint(Reset)))
 }
 
 func LogDebug(format string, args ...interface{}) {
@@ -88,32 +232,107 @@ func LogSuccess(format string, args ...interface{}) {
 	logger.log(SUCCESS, format, args...)
 }
 
+// taskIDWidth is the number of digits task IDs are zero-padded to in task logs
+var taskIDWidth = 1
+
+// SetTaskIDWidth sets how many digits task IDs are zero-padded to in task logs
+func SetTaskIDWidth(width int) {
+	if width < 1 {
+		width = 1
+	}
+	taskIDWidth = width
+}
+
 func LogTask(taskID int, format string, args ...interface{}) {
-	timestamp := time.Now().Format("15:04:05")
+	timestamp := logTimestamp()
 	message := fmt.Sprintf(format, args...)
-	fmt.Printf("%s[%s] [%sTASK-%d%s] %s%s\n",
-		Gray, timestamp, Cyan, taskID, Reset, message, Reset)
+	sendSyslog(INFO, fmt.Sprintf("TASK-%0*d %s", taskIDWidth, taskID, message))
+	if !logConsole {
+		return
+	}
+	writeLog(fmt.Sprintf("%s[%s] [%sTASK-%0*d%s] %s%s\n",
+		paint(Gray), timestamp, paint(Cyan), taskIDWidth, taskID, paint(Reset), message, paint(Reset)))
 }
 
 func LogPerf(format string, args ...interface{}) {
-	timestamp := time.Now().Format("15:04:05")
+	timestamp := logTimestamp()
 	message := fmt.Sprintf(format, args...)
-	fmt.Printf("%s[%s] [%sPERF%s] %s%s\n",
-		Gray, timestamp, Purple, Reset, message, Reset)
+	sendSyslog(INFO, "PERF "+message)
+	if !logConsole {
+		return
+	}
+	writeLog(fmt.Sprintf("%s[%s] [%sPERF%s] %s%s\n",
+		paint(Gray), timestamp, paint(Purple), paint(Reset), message, paint(Reset)))
 }
 
 func SetLogLevel(level LogLevel) {
 	logger.level = level
 }
 
+// DefaultTimestampFormat is the Go time layout of log timestamps unless --timestamp-format is given
+const DefaultTimestampFormat = "2006-01-02 15:04:05"
+
+var (
+	timestampFormat = DefaultTimestampFormat
+	timestampUTC    bool
+)
+
+// SetTimestampFormat sets the Go time layout of log timestamps and whether they are in UTC
+// instead of local time
+func SetTimestampFormat(layout string, utc bool) {
+	timestampFormat = layout
+	timestampUTC = utc
+}
+
+// logTimestamp is the current time as shown in log lines
+func logTimestamp() string {
+	now := time.Now()
+	if timestampUTC {
+		now = now.UTC()
+	}
+	return now.Format(timestampFormat)
+}
+
+// colorEnabled says whether log lines carry ANSI colors; see SetColorMode. It starts as "auto"
+// so that errors logged before the flags are parsed are plain when stderr is redirected.
+var colorEnabled = autoColor()
+
+// autoColor is the --color auto decision
+func autoColor() bool {
+	return os.Getenv("NO_COLOR") == "" && isColorSupported() && isTerminal(os.Stderr)
+}
+
+// SetColorMode applies --color: "always", "never", or "auto", which colors logs only when
+// stderr is a terminal that is not "dumb" and NO_COLOR (https://no-color.org) is not set
+func SetColorMode(mode string) error {
+	switch mode {
+	case "always":
+		colorEnabled = true
+	case "never":
+		colorEnabled = false
+	case "auto":
+		colorEnabled = autoColor()
+	default:
+		return fmt.Errorf("invalid --color '%s' (use auto, always or never)", mode)
+	}
+	return nil
+}
+
+// paint returns the ANSI code, or nothing when colors are off
+func paint(code string) string {
+	if !colorEnabled {
+		return ""
+	}
+	return code
+}
+
 func isColorSupported() bool {
 	term := os.Getenv("TERM")
 	return term != "" && !strings.Contains(strings.ToLower(term), "dumb")
 }
 
-func init() {
-	// Disable colors on Windows if not supported
-	if !isColorSupported() {
-		// Keep colors for now, most modern terminals support them
-	}
+// isTerminal reports whether file is a terminal rather than a file or pipe
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
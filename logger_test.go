@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// closedPipe returns the writing end of a pipe whose reader has gone away, like stdout piped into `head`
+func closedPipe() io.Writer {
+	reader, writer := io.Pipe()
+	reader.Close()
+	return writer
+}
+
+// captureConsole points the console and the log at test writers and restores them, including
+// the broken pipe state, once the test is done
+func captureConsole(t *testing.T, w io.Writer) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	SetConsoleWriter(w)
+	logOutput = &logs
+	t.Cleanup(func() {
+		SetConsoleWriter(os.Stdout)
+		SetBrokenPipeHandler(nil)
+		consoleMu.Lock()
+		logOutput = os.Stderr
+		consoleClosed = false
+		logClosed = false
+		brokenPipeNotified = sync.Once{}
+		consoleMu.Unlock()
+	})
+	return &logs
+}
+
+func TestBrokenPipeHandlerFiresOnce(t *testing.T) {
+	captureConsole(t, closedPipe())
+	calls := 0
+	SetBrokenPipeHandler(func() { calls++ })
+
+	for i := 0; i < 3; i++ {
+		ConsolePrintln("result")
+	}
+	if calls != 1 {
+		t.Fatalf("broken pipe handler called %d times, want 1", calls)
+	}
+}
+
+func TestOutputWriteFailedStopsOnce(t *testing.T) {
+	logs := captureConsole(t, io.Discard)
+	r := &Runner{outputPath: stdoutPath, cancelChan: make(chan struct{})}
+
+	_, err := io.WriteString(closedPipe(), "result\n")
+	if !isBrokenPipe(err) {
+		t.Fatalf("write to a closed pipe returned %v, want a broken pipe", err)
+	}
+	for i := 0; i < 3; i++ {
+		r.outputWriteFailed(err)
+	}
+
+	if !r.cancelled() {
+		t.Fatal("run was not cancelled after stdout closed")
+	}
+	if n := strings.Count(logs.String(), "Stdout was closed"); n != 1 {
+		t.Fatalf("stop warning logged %d times, want 1:\n%s", n, logs.String())
+	}
+}
+
+func TestLogsOutliveClosedConsole(t *testing.T) {
+	logs := captureConsole(t, closedPipe())
+
+	ConsolePrintln("result")
+	LogError("failed task report written")
+	if !strings.Contains(logs.String(), "failed task report written") {
+		t.Fatalf("log line dropped after the console pipe broke; logs:\n%s", logs.String())
+	}
+}
+
+func TestClosedLogDoesNotStopRun(t *testing.T) {
+	var console bytes.Buffer
+	captureConsole(t, &console)
+	logOutput = closedPipe()
+	calls := 0
+	SetBrokenPipeHandler(func() { calls++ })
+
+	LogError("nobody reads this")
+	ConsolePrintln("result")
+	if calls != 0 {
+		t.Fatalf("broken pipe handler called %d times for a closed log, want 0", calls)
+	}
+	if console.String() != "result\n" {
+		t.Fatalf("console got %q, want the result", console.String())
+	}
+}
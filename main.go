@@ -1,10 +1,16 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -13,6 +19,17 @@ var rootCmd = &cobra.Command{
 	Use:   "bulker",
 	Short: "Parallel processing tool for command-line utilities",
 	Long:  `A tool for running command-line utilities in parallel, with support for input file splitting and streamlined output handling.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := SetColorMode(colorMode); err != nil {
+			LogError("Error: %v", err)
+			os.Exit(1)
+		}
+		if logTimeFmt == "" {
+			LogError("Error: --timestamp-format cannot be empty")
+			os.Exit(1)
+		}
+		SetTimestampFormat(logTimeFmt, logUTC)
+	},
 }
 
 var runCmd = &cobra.Command{
@@ -29,28 +46,207 @@ var listCmd = &cobra.Command{
 	Run:   listTools,
 }
 
+var mergeCmd = &cobra.Command{
+	Use:   "merge [dir]",
+	Short: "Merge result files from a directory",
+	Long:  `Combines result files in a directory into a single output file, optionally sorted and de-duplicated.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   mergeResults,
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [dir]",
+	Short: "Show statistics for result files in a directory",
+	Long:  `Prints a table with the size and line count of each result file in a directory.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   showStats,
+}
+
 var (
-	inputFile  string
-	outputFile string
-	workers    int
-	extraArgs  []string
-	configFile string
-	wordlist   string
+	inputFile   string
+	outputFile  string
+	workers     int
+	extraArgs   []string
+	argsFile    string
+	configFile  string
+	profile     string
+	wordlist    string
+	splitBytes  string
+	scheduler   string
+	priorityIn  string
+	inputDir    string
+	inputGlob   string
+	tagSource   bool
+	perFile     bool
+	inputFormat string
+	jsonField   string
+	csvColumn   string
+	since       string
+	timeRegex   string
+	timeFormat  string
+	lineTimeout time.Duration
+	dedupInput  bool
+	skipBinary  bool
+	strictInput bool
+	shellName   string
+	appendNL    bool
+	stripANSI   bool
+	memLimit    string
+	maxRuntime  time.Duration
+	targets     []string
+	format      string
+	compress    bool
+	tail        bool
+	countOnly   bool
+	requireOut  bool
+	remoteHost  string
+	watch       bool
+	maxLineSize string
+	outputSplit int
+	lineEnding  string
+	colorMode   string
+	logTimeFmt  string
+	logUTC      bool
+	sample      float64
+	sampleSeed  int64
+	allowEmpty  bool
+	safeOutput  bool
+	watchEvery  time.Duration
+	noGlob      bool
+	rawCommand  string
+	modeFlag    string
+	windowName  string
+	logSyslogOn bool
+	logToStdout bool
+	shard       string
+	envVars     []string
+	progressInt time.Duration
+	notifyURL   string
+	metricsFile string
+	noComments  bool
+	keepTemp    bool
+	checksum    bool
+	checksumOut bool
+	collectErrs bool
+	errorsFile  string
+	failedDir   string
+	stderrTail  int
+	maxOutput   string
+	rotate      bool
+	outputBuf   int
+	flushEvery  time.Duration
+	outputMode  string
+	dirMode     string
+	umask       string
+	shutdownTO  time.Duration
+	startDelay  time.Duration
+
+	listJSON bool
+
+	mergeOutput  string
+	mergePattern string
+	mergeSort    bool
+	mergeUnique  bool
+	mergeCommand string
 )
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&logTimeFmt, "timestamp-format", DefaultTimestampFormat, "Go time layout of log timestamps (e.g. 15:04:05, or 2006-01-02T15:04:05Z07:00 for RFC 3339)")
+	rootCmd.PersistentFlags().BoolVar(&logUTC, "utc", false, "Log timestamps in UTC instead of local time")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", "auto", "Color log lines: auto (when stderr is a terminal and NO_COLOR is unset), always or never")
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(mergeCmd)
+	rootCmd.AddCommand(statsCmd)
 
 	runCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input file path (leave empty to read from stdin)")
-	runCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file path (required)")
+	runCmd.Flags().StringArrayVar(&targets, "targets", []string{}, "Comma-separated targets to use as input instead of a file (repeatable): --targets 'a.com,b.com'")
+	runCmd.Flags().StringVar(&inputDir, "input-dir", "", "Directory of input files to process as one stream (cannot be combined with --input)")
+	runCmd.Flags().StringVar(&inputGlob, "input-pattern", "*.txt", "Glob pattern for files in --input-dir")
+	runCmd.Flags().BoolVar(&perFile, "per-file", false, "Run each file of --input-dir (and its subdirectories) separately; --output is then a directory receiving one result file per input file")
+	runCmd.Flags().BoolVar(&tagSource, "tag-source", false, "Prefix each line from --input-dir with '<filename>:'")
+	runCmd.Flags().StringVar(&inputFormat, "input-format", "line", "Input format: 'line', 'json' (JSON lines, see --json-field) or 'csv' (see --csv-column)")
+	runCmd.Flags().StringVar(&jsonField, "json-field", "", "Dotted path of the value to use from each JSON input record (e.g. host or result.url)")
+	runCmd.Flags().StringVar(&since, "since", "", "Only process input lines timestamped after this time (in --time-format) or duration ago (e.g. 24h)")
+	runCmd.Flags().StringVar(&timeRegex, "time-regex", defaultTimeRegex, "Regex whose first capture group is the timestamp of an input line (for --since)")
+	runCmd.Flags().StringVar(&timeFormat, "time-format", time.RFC3339, "Go time layout of input line timestamps (for --since)")
+	runCmd.Flags().StringVar(&csvColumn, "csv-column", "", "CSV column to use as input: a header name, or a 1-based index when the file has no header")
+	runCmd.Flags().BoolVar(&allowEmpty, "allow-empty-input", false, "Finish without tasks when the input has no lines, instead of failing (always allowed with --watch)")
+	runCmd.Flags().Float64Var(&sample, "sample", 0, "Process only a random fraction of the input lines (e.g. 0.1 for 10%), for quick coverage checks")
+	runCmd.Flags().Int64Var(&sampleSeed, "seed", 0, "Seed for --sample, to pick the same lines again (default: random, shown in the log)")
+	runCmd.Flags().StringVar(&shard, "shard", "", "Only process lines in this shard, as index/count (e.g. 2/5); see README for the hashing used")
+	runCmd.Flags().StringVar(&maxLineSize, "max-line-size", "16MB", "Longest line accepted in the input, wordlist and tool output (e.g. 64MB for huge URLs or base64 blobs)")
+	runCmd.Flags().BoolVar(&dedupInput, "dedup-input", false, "Remove duplicate input lines before creating tasks (keeps first occurrence)")
+	runCmd.Flags().BoolVar(&skipBinary, "skip-binary", false, "Skip input lines with NUL bytes or invalid UTF-8 instead of stopping at a NUL byte (invalid UTF-8 alone is only warned about)")
+	runCmd.Flags().BoolVar(&strictInput, "strict", false, "Stop with an error on an input line that does not match the tool's input_pattern instead of skipping it, and on a tool older than its min_version")
+	runCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file path (required, supports {date}, {time} and {tool} placeholders; - writes results to stdout and logs to stderr)")
 	// Change short flag from -w to -t to avoid conflict with wordlist flag (-w in tools like ffuf)
 	runCmd.Flags().IntVarP(&workers, "threads", "t", 4, "Number of parallel threads")
 	runCmd.Flags().StringArrayVarP(&extraArgs, "extra-args", "e", []string{}, "Extra arguments to pass to the tool (supports multiple args in one flag: -e '--strict --verify')")
+	runCmd.Flags().StringVar(&argsFile, "args-file", "", "File of extra arguments for the tool, appended after -e ('#' lines are comments; arguments may span lines)")
 	runCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to custom config file")
+	runCmd.Flags().StringVar(&profile, "profile", "", "Config profile whose [profiles.<name>.tools] override the default tools (default: $BULKER_PROFILE)")
+	runCmd.Flags().StringArrayVar(&envVars, "env", []string{}, "Environment variable for the tool as KEY=VALUE (repeatable); {task_id} and {task_name} are expanded per task")
 	runCmd.Flags().StringVarP(&wordlist, "wordlist", "w", "", "Path to wordlist file (for tools like ffuf)")
+	runCmd.Flags().StringVar(&format, "format", "", "Format for parsed output records: text, json, csv or tsv (default: inferred from --output extension); task-json writes one JSON object per task")
+	runCmd.Flags().BoolVar(&compress, "gzip", false, "Gzip the output file (default: on when --output ends in .gz)")
+	runCmd.Flags().BoolVar(&countOnly, "count-only", false, "Run everything but only count result lines and print the total; no output file is written (--output not needed)")
+	runCmd.Flags().BoolVar(&requireOut, "require-output", false, "Exit with an error if the run produced no results (a header alone does not count), to catch silent misconfigurations")
+	runCmd.Flags().BoolVar(&tail, "tail", false, "Also print results to stdout as they are written to the output file")
+	runCmd.Flags().StringVar(&windowName, "window-name", "worker_{id}", "Task name format in logs; {id} is the zero-padded task ID")
+	runCmd.Flags().BoolVar(&logSyslogOn, "log-syslog", false, "Also send log messages to the local syslog/journald (not available on Windows)")
+	runCmd.Flags().BoolVar(&logToStdout, "log-console", true, "Write log messages to stderr (--log-console=false with --log-syslog for service runs)")
+	runCmd.Flags().DurationVar(&lineTimeout, "line-timeout", 0, "Kill a single-mode line that runs longer than this (e.g. 30s, 2m); 0 disables")
+	runCmd.Flags().StringVar(&rawCommand, "raw", "", "Run this command template instead of a configured tool, e.g. \"mytool -l {input} {args}\" (no config file needed)")
+	runCmd.Flags().StringVar(&modeFlag, "mode", "", "Override the tool's mode: multiple (one chunk file per task) or single (one input line per task); --raw defaults to multiple")
+	runCmd.Flags().BoolVar(&noGlob, "no-glob", false, "Stop the shell from expanding * and ? in tool commands (same as no_glob = true in the tool config)")
+	runCmd.Flags().BoolVar(&watch, "watch", false, "After the run, keep watching the input file and process lines appended to it, adding their results to the output")
+	runCmd.Flags().DurationVar(&watchEvery, "watch-interval", 5*time.Second, "How often --watch checks the input file for new lines")
+	runCmd.Flags().StringVar(&remoteHost, "remote", "", "Run every task on this host over SSH (user@host); input is sent over the connection and output comes back on it")
+	runCmd.Flags().StringVar(&shellName, "shell", "", "Shell used to run tool commands (default: $BULKER_SHELL, then bash or sh; cmd on Windows)")
+	runCmd.Flags().StringVar(&lineEnding, "line-ending", LineEndingLF, "Line ending for every line of the output: lf, crlf, or keep to write tool output as produced")
+	runCmd.Flags().BoolVar(&appendNL, "output-append-newline", true, "Make each task's merged output end with exactly one newline (--output-append-newline=false writes it as-is)")
+	runCmd.Flags().BoolVar(&stripANSI, "strip-ansi", true, "Remove ANSI color codes from tool output written to the output file")
+	runCmd.Flags().StringVar(&memLimit, "mem-limit", "", "Memory budget (e.g. 512MB, 2GB); fewer tasks are launched while usage is above it")
+	runCmd.Flags().DurationVar(&startDelay, "worker-start-delay", 0, "Delay between the first launches of each worker (e.g. 200ms), to ramp up gradually instead of all at once")
+	runCmd.Flags().DurationVar(&progressInt, "progress-interval", time.Second, "How often to log progress while tasks run (e.g. 10s, 1m); 0 disables progress logging")
+	runCmd.Flags().BoolVar(&collectErrs, "collect-errors", false, "Keep running when a task fails instead of stopping the run (default: stop on the first failure)")
+	runCmd.Flags().StringVar(&errorsFile, "errors-file", "", "Write failed tasks (input, command, exit code, stderr) to this file as JSON lines")
+	runCmd.Flags().StringVar(&failedDir, "failed-chunks", "", "Save the input lines of each failed task to task_<id>.txt in this directory, for re-running with -i")
+	runCmd.Flags().IntVar(&stderrTail, "stderr-tail", 10, "Number of trailing stderr lines kept per task for the failed-task report (0 keeps none)")
+	runCmd.Flags().BoolVar(&checksum, "checksum", false, "Log the SHA-256 of the output file when the run finishes")
+	runCmd.Flags().BoolVar(&checksumOut, "checksum-file", false, "Also write the SHA-256 to <output>.sha256 (implies --checksum)")
+	runCmd.Flags().DurationVar(&flushEvery, "flush-interval", defaultFlushInterval, "How often new output is flushed and synced to disk (e.g. 200ms, 5s); bounds what a crash can lose")
+	runCmd.Flags().IntVar(&outputBuf, "output-buffer", defaultOutputQueueSize, "Number of output blocks queued for the writer before tasks wait for it (bounds memory when tools outpace the disk)")
+	runCmd.Flags().StringVar(&outputMode, "output-mode", "", "Permissions for the output file, in octal (e.g. 0640; default 0666 less the umask)")
+	runCmd.Flags().StringVar(&dirMode, "dir-mode", "", "Permissions for output directories bulker creates, in octal (e.g. 0750; default 0755 less the umask)")
+	runCmd.Flags().StringVar(&umask, "umask", "", "Process umask in octal (e.g. 027), applied to every file bulker and its tools create (not supported on Windows)")
+	runCmd.Flags().BoolVar(&keepTemp, "keep-temp", false, "Keep chunk and temp output files after each task and log their paths (for debugging)")
+	runCmd.Flags().BoolVar(&noComments, "no-comments", false, "Keep input lines starting with the tool's comment_prefix (default \"#\") instead of skipping them")
+	runCmd.Flags().StringVar(&metricsFile, "metrics-file", "", "Write the run's task counts, duration and throughput to this file in Prometheus textfile collector format (e.g. <node_exporter textfile dir>/bulker.prom)")
+	runCmd.Flags().StringVar(&notifyURL, "notify-url", "", "POST a JSON summary of the run to this URL when it finishes or is interrupted")
+	runCmd.Flags().StringVar(&maxOutput, "max-output-size", "", "Stop the run when the output file would grow beyond this size (e.g. 500MB, 1GB)")
+	runCmd.Flags().IntVar(&outputSplit, "output-split", 0, "Write the output as out.part1.txt, out.part2.txt, ... of at most this many lines each, every part with the header")
+	runCmd.Flags().BoolVar(&safeOutput, "safe-output", false, "If the run fails or no task succeeds, put the previous output file back from its backup")
+	runCmd.Flags().BoolVar(&rotate, "rotate", false, "With --max-output-size, continue in <output>.1, <output>.2, ... instead of stopping")
+	runCmd.Flags().DurationVar(&shutdownTO, "shutdown-timeout", 5*time.Second, "How long stopped tasks get to exit after SIGTERM before they are killed")
+	runCmd.Flags().DurationVar(&maxRuntime, "max-runtime", 0, "Stop the whole run after this long, keeping partial results (e.g. 30m, 2h); 0 disables")
+	runCmd.Flags().StringVar(&priorityIn, "priority-file", "", "File of input lines to process first (implies --scheduler dynamic)")
+	runCmd.Flags().StringVar(&scheduler, "scheduler", "static", "Task scheduler: 'static' (one chunk per thread) or 'dynamic' (idle threads pull small units)")
+	runCmd.Flags().StringVar(&splitBytes, "split-bytes", "", "Split input into chunks of at most this size instead of by thread count (e.g. 512KB, 10MB, 1GB)")
 
 	listCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to custom config file")
+	listCmd.Flags().StringVar(&profile, "profile", "", "Config profile whose [profiles.<name>.tools] override the default tools (default: $BULKER_PROFILE)")
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "Print the tools as a JSON array instead of text")
+
+	mergeCmd.Flags().StringVarP(&mergeOutput, "output", "o", "", "Merged output file path (required)")
+	mergeCmd.Flags().StringVarP(&mergePattern, "pattern", "p", "*.txt", "Glob pattern for result files inside the directory")
+	mergeCmd.Flags().BoolVar(&mergeSort, "sort", false, "Sort merged lines (external sort, works on files larger than memory)")
+	mergeCmd.Flags().BoolVar(&mergeUnique, "unique", false, "Remove duplicate lines (implies --sort)")
+	mergeCmd.Flags().StringVar(&mergeCommand, "command", "", "Pipe the merged lines through this shell command (e.g. \"sort -u\") and write its output instead")
+	mergeCmd.Flags().StringVar(&shellName, "shell", "", "Shell used to run --command (default: $BULKER_SHELL, then bash or sh; cmd on Windows)")
+
+	statsCmd.Flags().StringVarP(&mergePattern, "pattern", "p", "*.txt", "Glob pattern for result files inside the directory")
 }
 
 func main() {
@@ -60,76 +256,304 @@ func main() {
 	}
 }
 
-// splitArgsRespectingQuotes splits a string into arguments while respecting quoted strings
+// splitArgsRespectingQuotes splits a string into arguments like a POSIX shell would, without
+// expanding anything: whitespace separates arguments except inside single or double quotes, and
+// quoted parts join the text around them (a"b c"'d' is one argument, ab cd). A backslash escapes
+// a quote, a backslash or whitespace outside quotes, and a double quote or backslash inside double
+// quotes, so -H "Cookie: a=\"b\"" gives -H and Cookie: a="b". Any other backslash is kept, so
+// Windows paths need no escaping. An unterminated quote runs to the end of the input.
 func splitArgsRespectingQuotes(input string) []string {
-	if strings.TrimSpace(input) == "" {
-		return []string{}
-	}
-
-	var args []string
+	args := []string{}
 	var current strings.Builder
-	inQuotes := false
+	inArg := false // Whether current holds an argument, possibly empty ("")
 	quoteChar := byte(0)
 
 	for i := 0; i < len(input); i++ {
 		char := input[i]
 
 		switch {
-		case (char == '"' || char == '\'') && !inQuotes:
-			inQuotes = true
+		case quoteChar == '\'':
+			// Single quotes: everything is literal up to the closing quote
+			if char == '\'' {
+				quoteChar = 0
+			} else {
+				current.WriteByte(char)
+			}
+		case quoteChar == '"':
+			switch {
+			case char == '"':
+				quoteChar = 0
+			case char == '\\' && i+1 < len(input) && (input[i+1] == '"' || input[i+1] == '\\'):
+				i++
+				current.WriteByte(input[i])
+			default:
+				current.WriteByte(char)
+			}
+		case char == '"' || char == '\'':
 			quoteChar = char
-		case char == quoteChar && inQuotes:
-			inQuotes = false
-			quoteChar = 0
-		case char == ' ' && !inQuotes:
-			if current.Len() > 0 {
+			inArg = true
+		case char == '\\' && i+1 < len(input) && strings.IndexByte("\"'\\ \t\n", input[i+1]) >= 0:
+			i++
+			current.WriteByte(input[i])
+			inArg = true
+		case char == ' ' || char == '\t' || char == '\n':
+			if inArg {
 				args = append(args, current.String())
 				current.Reset()
+				inArg = false
 			}
 		default:
 			current.WriteByte(char)
+			inArg = true
 		}
 	}
 
-	if current.Len() > 0 {
+	if inArg {
 		args = append(args, current.String())
 	}
 
 	return args
 }
 
+// readArgsFile reads extra tool arguments from a file, split like -e values. Lines starting with
+// '#' are comments and a trailing unescaped backslash is dropped; the remaining lines are joined
+// with spaces, so arguments may be spread over several lines and a quoted value may span a line
+// break. Inside such a value no line is a comment and blank lines are kept.
+func readArgsFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read args file: %w", err)
+	}
+
+	var parts []string
+	quoteChar := byte(0) // The quote still open at the start of the line
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if quoteChar == 0 && (line == "" || strings.HasPrefix(line, "#")) {
+			continue
+		}
+		var continued bool
+		quoteChar, continued = scanArgsLine(line, quoteChar)
+		if continued {
+			line = line[:len(line)-1]
+		}
+		parts = append(parts, line)
+	}
+	return splitArgsRespectingQuotes(strings.Join(parts, " ")), nil
+}
+
+// scanArgsLine follows the quoting rules of splitArgsRespectingQuotes through line, starting
+// inside quoteChar (0 for none). It returns the quote still open at the end of the line and
+// whether the line ends in a backslash that escapes nothing, outside quotes: a line continuation.
+func scanArgsLine(line string, quoteChar byte) (byte, bool) {
+	for i := 0; i < len(line); i++ {
+		char := line[i]
+		switch {
+		case quoteChar == '\'':
+			if char == '\'' {
+				quoteChar = 0
+			}
+		case quoteChar == '"':
+			if char == '"' {
+				quoteChar = 0
+			} else if char == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\') {
+				i++
+			}
+		case char == '"' || char == '\'':
+			quoteChar = char
+		case char == '\\':
+			if i+1 == len(line) {
+				return 0, true
+			}
+			if strings.IndexByte("\"'\\ \t", line[i+1]) >= 0 {
+				i++
+			}
+		}
+	}
+	return quoteChar, false
+}
+
+// inferOutputFormat picks the record format and compression from the output file extension:
+// .json/.jsonl -> json, .csv -> csv, .tsv -> tsv, and a trailing .gz enables gzip (e.g. out.json.gz).
+// Explicit --format and --gzip flags take precedence over the inferred values.
+func inferOutputFormat(path string) (string, bool) {
+	lower := strings.ToLower(path)
+	compressed := strings.HasSuffix(lower, ".gz")
+	lower = strings.TrimSuffix(lower, ".gz")
+
+	switch filepath.Ext(lower) {
+	case ".json", ".jsonl":
+		return "json", compressed
+	case ".csv":
+		return "csv", compressed
+	case ".tsv":
+		return "tsv", compressed
+	}
+	return "", compressed
+}
+
+// parseTargets splits each --targets value on commas and drops empty entries
+func parseTargets(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, target := range strings.Split(value, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				result = append(result, target)
+			}
+		}
+	}
+	return result
+}
+
+// resolveOutputTemplate replaces {date}, {time} and {tool} placeholders in the output path.
+// {date} and {time} use the same compact format as output backups (20060102 and 150405).
+// If the resolved file still exists (e.g. a second run on the same day with only {date}),
+// the usual backup of the existing output file applies.
+func resolveOutputTemplate(path, tool string, now time.Time) string {
+	replacer := strings.NewReplacer(
+		"{date}", now.Format("20060102"),
+		"{time}", now.Format("150405"),
+		"{tool}", strings.ToLower(tool),
+	)
+	return replacer.Replace(path)
+}
+
 func runCommand(cmd *cobra.Command, args []string) {
-	if len(args) == 0 {
+	if len(args) == 0 && rawCommand == "" {
 		// If no tool is specified, list available tools
 		listTools(cmd, args)
 		return
 	}
 
-	command := args[0]
+	if modeFlag != "" && modeFlag != "single" && modeFlag != "multiple" {
+		LogError("Error: invalid --mode '%s' (use single or multiple)", modeFlag)
+		os.Exit(1)
+	}
+
+	// With --raw there is no tool name; every argument goes to {args}
+	var command string
+	var commandArgs []string
+	if rawCommand != "" {
+		command = rawToolName
+		commandArgs = args
+	} else {
+		command = args[0]
+		commandArgs = args[1:]
+	}
+
+	if logSyslogOn {
+		if err := EnableSyslog("bulker"); err != nil {
+			LogError("Error: --log-syslog: %v", err)
+			os.Exit(1)
+		}
+	}
+	SetConsoleLogging(logToStdout)
+	if outputFile == stdoutPath {
+		// Results go to stdout, so the progress tools print must not
+		SetConsoleWriter(os.Stderr)
+		if tail || perFile || outputSplit > 0 || maxOutput != "" || checksum || checksumOut {
+			LogError("Error: --output - cannot be combined with --tail, --per-file, --output-split, --max-output-size, --checksum or --checksum-file")
+			os.Exit(1)
+		}
+	}
 
 	// Determine if stdin is being piped
 	stdinInfo, _ := os.Stdin.Stat()
 	stdinIsPipe := stdinInfo.Mode()&os.ModeCharDevice == 0
 
-	// Validate input source
-	if inputFile == "" && !stdinIsPipe {
-		LogError("Error: --input flag is required when running a command (or provide input via stdin)")
+	// Validate input source: at most one of --input, --input-dir and --targets; stdin only when none is given
+	targetList := parseTargets(targets)
+	inputSources := 0
+	for _, given := range []bool{inputFile != "", inputDir != "", len(targets) > 0} {
+		if given {
+			inputSources++
+		}
+	}
+	if inputSources > 1 {
+		LogError("Error: only one of --input, --input-dir or --targets can be used")
+		os.Exit(1)
+	}
+	if inputSources == 0 && !stdinIsPipe {
+		LogError("Error: --input flag is required when running a command (or use --input-dir, --targets, or provide input via stdin)")
 		cmd.Help()
 		os.Exit(1)
 	}
+	if len(targets) > 0 && len(targetList) == 0 {
+		LogError("Error: --targets did not contain any targets")
+		os.Exit(1)
+	}
+
+	// Fail before any setup if the input or config cannot be read
+	var preflight []error
+	if inputFile != "" {
+		preflight = append(preflight, checkReadableFile("input file", inputFile))
+	}
+	if inputDir != "" {
+		preflight = append(preflight, checkReadableDir("input directory", inputDir))
+	}
+	if configFile != "" && rawCommand == "" {
+		preflight = append(preflight, checkReadableFile("config file", configFile))
+	}
+	if err := errors.Join(preflight...); err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+
+	if watch && inputFile == "" {
+		LogError("Error: --watch needs an input file given with --input")
+		os.Exit(1)
+	}
+	if perFile && inputDir == "" {
+		LogError("Error: --per-file needs an input directory given with --input-dir")
+		os.Exit(1)
+	}
+	if perFile && (tagSource || watch || countOnly || metricsFile != "" || errorsFile != "") {
+		LogError("Error: --per-file cannot be combined with --tag-source, --watch, --count-only, --metrics-file or --errors-file")
+		os.Exit(1)
+	}
+	if watch && watchEvery <= 0 {
+		LogError("Error: --watch-interval must be positive")
+		os.Exit(1)
+	}
 
-	// Output file is always required
-	if outputFile == "" {
+	// Output file is required unless results are only counted
+	if countOnly {
+		if outputFile != "" {
+			LogWarn("--count-only writes no output, ignoring --output %s", outputFile)
+			outputFile = ""
+		}
+		if tail || checksum || checksumOut || maxOutput != "" {
+			LogError("Error: --count-only cannot be combined with --tail, --checksum, --checksum-file or --max-output-size")
+			os.Exit(1)
+		}
+	} else if outputFile == "" {
 		LogError("Error: --output flag is required when running a command")
 		cmd.Help()
 		os.Exit(1)
 	}
 
 	// Kiểm tra cấu hình tool để xác định các yêu cầu đặc biệt
-	configManager, err := NewConfigManager(configFile)
-	if err != nil {
-		LogError("Error loading config file: %v", err)
-		os.Exit(1)
+	var configManager *ConfigManager
+	var rawTool *ToolConfig
+	var err error
+	if rawCommand != "" {
+		mode := modeFlag
+		if mode == "" {
+			mode = "multiple"
+		}
+		tool, err := newRawToolConfig(rawCommand, mode)
+		if err != nil {
+			LogError("Error: %v", err)
+			os.Exit(1)
+		}
+		rawTool = &tool
+		configManager = NewRawConfigManager(tool)
+	} else {
+		configManager, err = NewConfigManager(configFile, profile)
+		if err != nil {
+			LogError("Error loading config file: %v", err)
+			os.Exit(1)
+		}
 	}
 
 	toolConfig, exists := configManager.GetToolConfig(command)
@@ -137,6 +561,10 @@ func runCommand(cmd *cobra.Command, args []string) {
 		LogError("Error: tool '%s' not found in config file", command)
 		os.Exit(1)
 	}
+	if modeFlag != "" {
+		// The checks below must see the mode the run will use
+		toolConfig.Mode = modeFlag
+	}
 
 	// Kiểm tra xem tool có yêu cầu wordlist không
 	if strings.Contains(toolConfig.Command, "{wordlist}") && wordlist == "" {
@@ -144,7 +572,21 @@ func runCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	commandArgs := args[1:]
+	// Resolve {date}/{time}/{tool} placeholders in the output path once, at startup
+	resolvedOutput := resolveOutputTemplate(outputFile, command, time.Now())
+	if resolvedOutput != outputFile {
+		LogInfo("Output path resolved to: %s", resolvedOutput)
+	}
+	if !countOnly && resolvedOutput != stdoutPath {
+		dir := filepath.Dir(resolvedOutput)
+		if perFile {
+			dir = resolvedOutput
+		}
+		if err := checkOutputDir(dir); err != nil {
+			LogError("Error: %v", err)
+			os.Exit(1)
+		}
+	}
 
 	// Process extra args - split each arg string by spaces to allow multiple args in one flag
 	if len(extraArgs) > 0 {
@@ -156,17 +598,278 @@ func runCommand(cmd *cobra.Command, args []string) {
 		}
 		commandArgs = append(commandArgs, processedArgs...)
 	}
+	if argsFile != "" {
+		fileArgs, err := readArgsFile(argsFile)
+		if err != nil {
+			LogError("Error: %v", err)
+			os.Exit(1)
+		}
+		LogInfo("Read %d extra arguments from %s", len(fileArgs), argsFile)
+		commandArgs = append(commandArgs, fileArgs...)
+	}
 
-	runner, err := NewRunner(RunnerConfig{
-		InputFile:   inputFile,
-		OutputFile:  outputFile,
-		Workers:     workers,
-		Command:     command,
-		CommandArgs: commandArgs,
-		ConfigFile:  configFile,
-		Wordlist:    wordlist,
-	})
+	if scheduler != "static" && scheduler != "dynamic" {
+		LogError("Error: --scheduler must be 'static' or 'dynamic', got '%s'", scheduler)
+		os.Exit(1)
+	}
 
+	if priorityIn != "" && scheduler == "static" {
+		// The static scheduler starts tasks in no particular order, so priorities need the work queue
+		LogInfo("--priority-file uses the dynamic scheduler so tasks start in priority order")
+		scheduler = "dynamic"
+	}
+
+	if lineTimeout > 0 && toolConfig.Mode != "single" {
+		LogWarn("--line-timeout only applies to tools in 'single' mode; ignoring for %s", command)
+	}
+
+	shell, err := resolveShell(shellName)
+	if err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+
+	if toolConfig.MinVersion != "" {
+		if remoteHost != "" || toolConfig.Container != "" {
+			LogInfo("Skipping the min_version check of %s: the tool runs on another host or in a container", command)
+		} else if err := checkToolVersion(command, toolConfig, shell); err != nil {
+			if strictInput {
+				LogError("Error: %v", err)
+				os.Exit(1)
+			}
+			LogWarn("%v", err)
+		}
+	}
+
+	var splitBytesValue int64
+	if splitBytes != "" {
+		splitBytesValue, err = parseByteSize(splitBytes)
+		if err != nil {
+			LogError("Error: invalid --split-bytes value: %v", err)
+			os.Exit(1)
+		}
+		if toolConfig.Mode != "multiple" && !toolConfig.SplitWordlist {
+			LogWarn("--split-bytes only applies to tools in 'multiple' mode or with split_wordlist; ignoring for %s", command)
+		}
+	}
+
+	outputFormat, compressOutput := inferOutputFormat(resolvedOutput)
+	if cmd.Flags().Changed("format") {
+		outputFormat = format
+	}
+	if cmd.Flags().Changed("gzip") {
+		compressOutput = compress
+	}
+	switch outputFormat {
+	case "", "text", "json", "csv", "tsv", taskJSONFormat:
+	default:
+		LogError("Error: --format must be one of text, json, csv, tsv or %s, got '%s'", taskJSONFormat, outputFormat)
+		os.Exit(1)
+	}
+	if cmd.Flags().Changed("format") && outputFormat != "text" && outputFormat != taskJSONFormat && toolConfig.Parser == "" {
+		LogWarn("--format only applies to tools with a parser; %s output is written as-is", command)
+	}
+
+	for _, entry := range envVars {
+		if key, _, found := strings.Cut(entry, "="); !found || key == "" {
+			LogError("Error: invalid --env value '%s', expected KEY=VALUE", entry)
+			os.Exit(1)
+		}
+	}
+
+	if sample < 0 || sample > 1 {
+		LogError("Error: --sample must be a fraction between 0 and 1 (e.g. 0.1 for 10%%)")
+		os.Exit(1)
+	}
+	if sample > 0 && !cmd.Flags().Changed("seed") {
+		sampleSeed = time.Now().UnixNano()
+	}
+
+	shardIndex, shardCount := 0, 1
+	if shard != "" {
+		shardIndex, shardCount, err = parseShard(shard)
+		if err != nil {
+			LogError("Error: invalid --shard value: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := checkInputFormat(inputFormat, jsonField, csvColumn); err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+
+	if stderrTail < 0 {
+		LogError("Error: --stderr-tail cannot be negative")
+		os.Exit(1)
+	}
+
+	if outputBuf < 1 {
+		LogError("Error: --output-buffer must be at least 1")
+		os.Exit(1)
+	}
+	if flushEvery <= 0 {
+		LogError("Error: --flush-interval must be positive")
+		os.Exit(1)
+	}
+
+	var memLimitValue int64
+	if memLimit != "" {
+		memLimitValue, err = parseByteSize(memLimit)
+		if err != nil {
+			LogError("Error: invalid --mem-limit value: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	maxLineValue, err := parseByteSize(maxLineSize)
+	if err != nil {
+		LogError("Error: invalid --max-line-size value: %v", err)
+		os.Exit(1)
+	}
+
+	var maxOutputValue int64
+	if maxOutput != "" {
+		maxOutputValue, err = parseByteSize(maxOutput)
+		if err != nil {
+			LogError("Error: invalid --max-output-size value: %v", err)
+			os.Exit(1)
+		}
+	}
+	if rotate && maxOutputValue == 0 {
+		LogError("Error: --rotate requires --max-output-size")
+		os.Exit(1)
+	}
+	if watch && maxOutputValue > 0 {
+		LogError("Error: --watch cannot be combined with --max-output-size; each batch would find the output already full")
+		os.Exit(1)
+	}
+	lineEnding = strings.ToLower(lineEnding)
+	if lineEnding != LineEndingLF && lineEnding != LineEndingCRLF && lineEnding != LineEndingKeep {
+		LogError("Error: invalid --line-ending '%s' (use lf, crlf or keep)", lineEnding)
+		os.Exit(1)
+	}
+	if safeOutput && outputSplit > 0 {
+		LogError("Error: --safe-output cannot be combined with --output-split, whose parts are not backed up")
+		os.Exit(1)
+	}
+	if outputSplit < 0 {
+		LogError("Error: --output-split must be a positive line count")
+		os.Exit(1)
+	}
+	if outputSplit > 0 && (countOnly || maxOutputValue > 0 || checksum || checksumOut || watch) {
+		LogError("Error: --output-split cannot be combined with --count-only, --max-output-size, --checksum, --checksum-file or --watch")
+		os.Exit(1)
+	}
+
+	var outputModeValue, dirModeValue os.FileMode
+	if outputMode != "" {
+		outputModeValue, err = parseFileMode(outputMode)
+		if err != nil {
+			LogError("Error: invalid --output-mode value: %v", err)
+			os.Exit(1)
+		}
+	}
+	if dirMode != "" {
+		dirModeValue, err = parseFileMode(dirMode)
+		if err != nil {
+			LogError("Error: invalid --dir-mode value: %v", err)
+			os.Exit(1)
+		}
+	}
+	if umask != "" {
+		mask, err := parseFileMode(umask)
+		if err != nil {
+			LogError("Error: invalid --umask value: %v", err)
+			os.Exit(1)
+		}
+		if err := setUmask(int(mask)); err != nil {
+			LogError("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	runnerConfig := RunnerConfig{
+		InputFile:        inputFile,
+		OutputFile:       resolvedOutput,
+		Workers:          workers,
+		Command:          command,
+		CommandArgs:      commandArgs,
+		ConfigFile:       configFile,
+		Profile:          profile,
+		Wordlist:         wordlist,
+		SplitBytes:       splitBytesValue,
+		Scheduler:        scheduler,
+		PriorityFile:     priorityIn,
+		InputDir:         inputDir,
+		InputPattern:     inputGlob,
+		TagSource:        tagSource,
+		InputFormat:      inputFormat,
+		JSONField:        jsonField,
+		CSVColumn:        csvColumn,
+		Since:            since,
+		TimeRegex:        timeRegex,
+		TimeFormat:       timeFormat,
+		LineTimeout:      lineTimeout,
+		DedupInput:       dedupInput,
+		SkipBinary:       skipBinary,
+		Strict:           strictInput,
+		Shell:            shell,
+		AppendNewline:    appendNL,
+		LineEnding:       lineEnding,
+		StripANSI:        stripANSI,
+		MemLimit:         memLimitValue,
+		MaxRuntime:       maxRuntime,
+		Targets:          targetList,
+		OutputFormat:     outputFormat,
+		Compress:         compressOutput,
+		Tail:             tail,
+		CountOnly:        countOnly,
+		RequireOutput:    requireOut,
+		MaxLineSize:      int(maxLineValue),
+		Remote:           remoteHost,
+		NoGlob:           noGlob,
+		RawTool:          rawTool,
+		Mode:             modeFlag,
+		ProgressInterval: progressInt,
+		NotifyURL:        notifyURL,
+		MetricsFile:      metricsFile,
+		NoComments:       noComments,
+		KeepTemp:         keepTemp,
+		ShutdownTimeout:  shutdownTO,
+		WorkerStartDelay: startDelay,
+		MaxOutputSize:    maxOutputValue,
+		Rotate:           rotate,
+		OutputSplit:      outputSplit,
+		OutputBuffer:     outputBuf,
+		FlushInterval:    flushEvery,
+		OutputMode:       outputModeValue,
+		DirMode:          dirModeValue,
+		Checksum:         checksum,
+		CollectErrors:    collectErrs,
+		ErrorsFile:       errorsFile,
+		FailedChunksDir:  failedDir,
+		StderrTail:       stderrTail,
+		ChecksumFile:     checksumOut,
+		WindowNameFormat: windowName,
+		AllowEmptyInput:  allowEmpty || watch,
+		SafeOutput:       safeOutput,
+		Sample:           sample,
+		SampleSeed:       sampleSeed,
+		ShardIndex:       shardIndex,
+		ShardCount:       shardCount,
+		Env:              envVars,
+	}
+
+	if perFile {
+		if err := runPerFile(runnerConfig, resolvedOutput); err != nil {
+			LogError("Error: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	runner, err := NewRunner(runnerConfig)
 	if err != nil {
 		LogError("Error creating runner: %v", err)
 		os.Exit(1)
@@ -176,18 +879,92 @@ func runCommand(cmd *cobra.Command, args []string) {
 		LogError("Error: %v", err)
 		os.Exit(1)
 	}
+
+	if watch && !runner.cancelled() {
+		if err := watchInput(runnerConfig, runner, watchEvery); err != nil {
+			LogError("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func mergeResults(cmd *cobra.Command, args []string) {
+	if mergeOutput == "" {
+		LogError("Error: --output flag is required when merging results")
+		cmd.Help()
+		os.Exit(1)
+	}
+
+	if mergeCommand != "" && (mergeSort || mergeUnique) {
+		LogError("Error: --command cannot be combined with --sort or --unique")
+		os.Exit(1)
+	}
+
+	collector := NewResultCollector(args[0], mergePattern, mergeOutput)
+
+	var err error
+	if mergeCommand != "" {
+		shell, shellErr := resolveShell(shellName)
+		if shellErr != nil {
+			LogError("Error: %v", shellErr)
+			os.Exit(1)
+		}
+		err = collector.MergeResultsCommand(mergeCommand, shell)
+	} else if mergeSort || mergeUnique {
+		err = collector.MergeResultsSorted(mergeUnique)
+	} else {
+		err = collector.MergeResults()
+	}
+	if err != nil {
+		LogError("Error merging results: %v", err)
+		os.Exit(1)
+	}
+
+	LogSuccess("Merged results written to: %s", mergeOutput)
+}
+
+func showStats(cmd *cobra.Command, args []string) {
+	collector := NewResultCollector(args[0], mergePattern, "")
+	stats, err := collector.GetMergeStats()
+	if err != nil {
+		LogError("Error reading result files: %v", err)
+		os.Exit(1)
+	}
+
+	writeStatsTable(os.Stdout, stats)
+}
+
+// writeStatsTable prints one row per result file and a total row, in aligned columns
+func writeStatsTable(w io.Writer, stats MergeStats) {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "FILE\tSIZE\tLINES\tMODIFIED")
+	for _, file := range stats.Files {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%s\n", file.Name, file.Size, file.Lines, file.ModTime.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(writer, "TOTAL (%d files)\t%d\t%d\t\n", stats.ResultFiles, stats.TotalSize, stats.TotalLines)
+	writer.Flush()
+}
+
+// ToolListing is one tool in the output of `list --json`
+type ToolListing struct {
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Mode              string   `json:"mode"`
+	Command           string   `json:"command"`
+	AutoOptimizations []string `json:"auto_optimizations"`
+	Examples          []string `json:"examples"`
 }
 
 func listTools(cmd *cobra.Command, args []string) {
-	configManager, err := NewConfigManager(configFile)
+	configManager, err := NewConfigManager(configFile, profile)
 	if err != nil {
 		LogWarn("Could not load config file: %v. No tools available.", err)
+		if listJSON {
+			fmt.Println("[]")
+		}
 		return
 	}
 
-	fmt.Println("Available tools:")
-	fmt.Println("================")
-
 	// Get tools from config file
 	tools := configManager.GetAllTools()
 
@@ -196,6 +973,14 @@ func listTools(cmd *cobra.Command, args []string) {
 		return tools[i].Name < tools[j].Name
 	})
 
+	if listJSON {
+		printToolsJSON(tools)
+		return
+	}
+
+	fmt.Println("Available tools:")
+	fmt.Println("================")
+
 	for _, tool := range tools {
 		fmt.Printf("\n%s\n", tool.Name)
 		fmt.Printf("  Description: %s\n", tool.Description)
@@ -216,15 +1001,54 @@ func listTools(cmd *cobra.Command, args []string) {
 	fmt.Println("  bulker run <tool> --input <file> --output <file> [flags]")
 	fmt.Println("\nCommon flags:")
 	fmt.Println("  -i, --input <file>     Input file path (required)")
+	fmt.Println("  --targets <list>       Comma-separated targets instead of an input file")
+	fmt.Println("  --input-dir <dir>      Process all *.txt files in a directory as one input")
+	fmt.Println("  --dedup-input          Skip duplicate input lines")
 	fmt.Println("  -o, --output <file>    Output file path (required)")
+	fmt.Println("                         Supports {date}, {time} and {tool}: -o 'scans/{tool}_{date}.txt'")
 	fmt.Println("  -t, --threads <num>    Number of parallel threads (default: 4)")
 	fmt.Println("  -e, --extra-args       Extra arguments to pass to the tool")
 	fmt.Println("                         Examples: -e '--strict --verify' or -e '--timeout 30'")
 	fmt.Println("  -w, --wordlist <file>  Wordlist file (required for ffuf)")
 	fmt.Println("  -c, --config <file>    Custom config file")
+	fmt.Println("  --env KEY=VALUE        Environment variable for the tool ({task_id} expands per task)")
+	fmt.Println("  --max-runtime <dur>    Stop the whole run after this long (e.g. 30m)")
+	fmt.Println("  --line-timeout <dur>   Kill a single-mode line after this long (e.g. 30s)")
+	fmt.Println("  --shell <name>         Shell for tool commands (or set BULKER_SHELL)")
+	fmt.Println("  --scheduler <mode>     static (default) or dynamic work pulling")
+	fmt.Println("  --split-bytes <size>   Split input by size instead of thread count (e.g. 10MB)")
 	fmt.Println("")
 	fmt.Println("Config file priority (config.toml):")
 	fmt.Println("  1. Current directory")
 	fmt.Println("  2. User's home directory")
 	fmt.Println("  Use -c flag to specify a custom path")
 }
+
+// printToolsJSON writes tools as an indented JSON array, with empty lists as [] rather than null
+func printToolsJSON(tools []ToolConfig) {
+	listings := make([]ToolListing, 0, len(tools))
+	for _, tool := range tools {
+		listing := ToolListing{
+			Name:              tool.Name,
+			Description:       tool.Description,
+			Mode:              tool.Mode,
+			Command:           tool.Command,
+			AutoOptimizations: tool.AutoOptimizations,
+			Examples:          tool.Examples,
+		}
+		if listing.AutoOptimizations == nil {
+			listing.AutoOptimizations = []string{}
+		}
+		if listing.Examples == nil {
+			listing.Examples = []string{}
+		}
+		listings = append(listings, listing)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(listings); err != nil {
+		LogError("Failed to write tool list: %v", err)
+	}
+}
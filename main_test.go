@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgsRespectingQuotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", []string{}},
+		{"only spaces", "   \t ", []string{}},
+		{"repeated spaces", "-a   b \t c", []string{"-a", "b", "c"}},
+		{"double quotes", `-H "User-Agent: x y"`, []string{"-H", "User-Agent: x y"}},
+		{"single quotes", `--match 'a b'`, []string{"--match", "a b"}},
+		{"double inside single", `'say "hi"'`, []string{`say "hi"`}},
+		{"single inside double", `"it's"`, []string{"it's"}},
+		{"quoted parts join", `a"b c"'d'`, []string{"ab cd"}},
+		{"escaped quote in double quotes", `-H "Cookie: a=\"b\""`, []string{"-H", `Cookie: a="b"`}},
+		{"escaped quote outside quotes", `\"x\"`, []string{`"x"`}},
+		{"escaped space", `a\ b c`, []string{"a b", "c"}},
+		{"backslash kept in single quotes", `'a\"b'`, []string{`a\"b`}},
+		{"windows path", `C:\tools\x.exe`, []string{`C:\tools\x.exe`}},
+		{"empty quoted argument", `a "" b`, []string{"a", "", "b"}},
+		{"unterminated double quote", `a "b c`, []string{"a", "b c"}},
+		{"unterminated single quote", `a 'b  c`, []string{"a", "b  c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitArgsRespectingQuotes(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitArgsRespectingQuotes(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsTable(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt": "one\ntwo\nthree\n",
+		"b.txt": "last line without newline",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := NewResultCollector(dir, "*.txt", "").GetMergeStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	writeStatsTable(&out, stats)
+
+	// Columns: FILE SIZE LINES MODIFIED (date and time)
+	rows := make(map[string][]string)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		fields := strings.Fields(line)
+		rows[fields[0]] = fields
+	}
+	want := map[string][2]string{
+		"a.txt": {"14", "3"},
+		"b.txt": {"25", "1"},
+		"TOTAL": {"39", "4"},
+	}
+	for name, sizeLines := range want {
+		fields, ok := rows[name]
+		if !ok {
+			t.Fatalf("no row for %s in:\n%s", name, out.String())
+		}
+		if name == "TOTAL" {
+			fields = fields[2:] // "TOTAL (2 files)"
+		}
+		if fields[1] != sizeLines[0] || fields[2] != sizeLines[1] {
+			t.Errorf("%s: SIZE %s LINES %s, want %s and %s", name, fields[1], fields[2], sizeLines[0], sizeLines[1])
+		}
+	}
+	if header := rows["FILE"]; len(header) != 4 || header[1] != "SIZE" || header[2] != "LINES" {
+		t.Errorf("header = %q", header)
+	}
+}
+
+func TestReadArgsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"one per line", "-H\n\"X-A: 1\"\n", []string{"-H", "X-A: 1"}},
+		{"comments and blank lines", "# headers\n\n-H x\n  # indented comment\n-v", []string{"-H", "x", "-v"}},
+		{"continuation", "-H \\\n  x", []string{"-H", "x"}},
+		{"continuation glued", "-w list\\\n.txt", []string{"-w", "list", ".txt"}},
+		{"escaped trailing backslash", "--path C:\\\\\n-v", []string{"--path", `C:\`, "-v"}},
+		{"unescaped trailing backslash continues", `--dir C:\tools\`, []string{"--dir", `C:\tools`}},
+		{"hash inside multi-line quote", "--data \"a\n# not a comment\nb\"", []string{"--data", "a # not a comment b"}},
+		{"hash inside single quotes", "--match 'x\n#y'", []string{"--match", "x #y"}},
+		{"comment after closed quote", "--data \"a\nb\"\n# comment\n-v", []string{"--data", "a b", "-v"}},
+		{"escaped quote does not open", "\\\"\n# comment", []string{`"`}},
+		{"backslash in double quotes kept", "\"a\\\n#b\"", []string{`a\ #b`}},
+		{"crlf", "-H x\r\n# c\r\n-v\r\n", []string{"-H", "x", "-v"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "args.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readArgsFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readArgsFile(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsPrefix starts the name of every metric in --metrics-file
+const metricsPrefix = "bulker_"
+
+// metricsWriter builds a Prometheus text exposition, every sample labelled with the tool
+type metricsWriter struct {
+	builder strings.Builder
+	tool    string
+}
+
+// gauge writes one metric with its HELP and TYPE lines. Every value describes the last run, so
+// all metrics are gauges: the textfile collector shows whatever the file holds.
+func (m *metricsWriter) gauge(name, help string, value float64) {
+	m.help(name, help)
+	m.sample(name, "", value)
+}
+
+func (m *metricsWriter) help(name, help string) {
+	fmt.Fprintf(&m.builder, "# HELP %s%s %s\n", metricsPrefix, name, help)
+	fmt.Fprintf(&m.builder, "# TYPE %s%s gauge\n", metricsPrefix, name)
+}
+
+// sample writes one sample; labels, if any, are added after the tool label
+func (m *metricsWriter) sample(name, labels string, value float64) {
+	all := `tool="` + labelEscaper.Replace(m.tool) + `"`
+	if labels != "" {
+		all += "," + labels
+	}
+	fmt.Fprintf(&m.builder, "%s%s{%s} %s\n", metricsPrefix, name, all, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// labelEscaper escapes label values as the text format expects
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetricsFile writes the run summary to path in the Prometheus textfile collector format. The
+// file is written next to path and renamed over it, so the collector never reads half a file.
+func writeMetricsFile(path string, summary RunNotification, mode os.FileMode) error {
+	m := &metricsWriter{tool: summary.Tool}
+
+	m.help("tasks", "Tasks of the last run by status.")
+	m.sample("tasks", `status="completed"`, float64(summary.Completed))
+	m.sample("tasks", `status="failed"`, float64(summary.Failed))
+	m.sample("tasks", `status="unfinished"`, float64(summary.Unfinished))
+	m.gauge("input_lines", "Input lines processed by the last run.", float64(summary.InputLines))
+	m.gauge("output_bytes", "Bytes written to the output by the last run, before compression.", float64(summary.BytesWritten))
+	m.gauge("run_duration_seconds", "Duration of the last run.", summary.Duration)
+	m.gauge("lines_per_second", "Input lines processed per second in the last run.", summary.LinesPerSecond)
+	success := 0.0
+	if summary.Status == "completed" {
+		success = 1
+	}
+	m.gauge("run_success", "Whether the last run completed without failed, unfinished or interrupted tasks.", success)
+	m.gauge("run_timestamp_seconds", "Unix time the last run ended.", float64(time.Now().Unix()))
+
+	dir := filepath.Dir(path)
+	temp := filepath.Join(dir, "."+filepath.Base(path)+".tmp")
+	file, err := createFileMode(temp, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(m.builder.String()); err != nil {
+		file.Close()
+		os.Remove(temp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
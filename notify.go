@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// notifyTimeout bounds how long the completion webhook may delay the end of a run
+const notifyTimeout = 10 * time.Second
+
+// RunNotification is the JSON payload POSTed to --notify-url when a run ends
+type RunNotification struct {
+	RunID      string  `json:"run_id"`
+	Tool       string  `json:"tool"`
+	Output     string  `json:"output"`
+	Status     string  `json:"status"`
+	Total      int     `json:"total"`
+	Completed  int     `json:"completed"`
+	Failed     int     `json:"failed"`
+	Unfinished int     `json:"unfinished"`
+	InputLines int     `json:"input_lines"`
+	Duration   float64 `json:"duration_seconds"`
+	// Throughput, as shown in the performance metrics
+	LinesPerSecond float64 `json:"lines_per_second"`
+	TasksPerSecond float64 `json:"tasks_per_second"`
+	BytesWritten   int64   `json:"bytes_written"`
+}
+
+// sendNotification POSTs the payload to url. Errors are returned for the caller to log, never to fail the run.
+func sendNotification(url string, payload RunNotification) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: notifyTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
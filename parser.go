@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OutputRecord is the normalized form of one line of tool output
+type OutputRecord struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Length int64  `json:"length,omitempty"`
+}
+
+// OutputParser converts one line of tool output into a normalized record.
+// It returns false for lines that carry no result (banners, blank lines, ...), which are dropped.
+type OutputParser func(line string) (OutputRecord, bool)
+
+// outputParsers is the registry of built-in parsers, selected by the tool's `parser` field
+var outputParsers = map[string]OutputParser{
+	"url":        parseURLLine,
+	"httpx-json": parseHttpxJSONLine,
+}
+
+// RegisterOutputParser adds or replaces a parser in the registry
+func RegisterOutputParser(name string, parser OutputParser) {
+	outputParsers[name] = parser
+}
+
+// GetOutputParser returns the parser registered under name
+func GetOutputParser(name string) (OutputParser, error) {
+	parser, exists := outputParsers[name]
+	if !exists {
+		names := make([]string, 0, len(outputParsers))
+		for n := range outputParsers {
+			names = append(names, n)
+		}
+		return nil, fmt.Errorf("unknown parser '%s' (available: %s)", name, strings.Join(names, ", "))
+	}
+	return parser, nil
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// parseURLLine extracts the first URL found in the line
+func parseURLLine(line string) (OutputRecord, bool) {
+	url := urlPattern.FindString(line)
+	if url == "" {
+		return OutputRecord{}, false
+	}
+	return OutputRecord{URL: url}, true
+}
+
+// parseHttpxJSONLine parses a line of `httpx -json` output
+func parseHttpxJSONLine(line string) (OutputRecord, bool) {
+	var result struct {
+		URL           string `json:"url"`
+		StatusCode    int    `json:"status_code"`
+		ContentLength int64  `json:"content_length"`
+	}
+	if err := json.Unmarshal([]byte(line), &result); err != nil || result.URL == "" {
+		return OutputRecord{}, false
+	}
+	return OutputRecord{URL: result.URL, Status: result.StatusCode, Length: result.ContentLength}, true
+}
+
+// formatRecord renders a record as a JSON object, or as a tab- or comma-separated url/status/length row
+func formatRecord(record OutputRecord, format string) string {
+	fields := []string{
+		record.URL,
+		strconv.Itoa(record.Status),
+		strconv.FormatInt(record.Length, 10),
+	}
+
+	switch format {
+	case "tsv":
+		return strings.Join(fields, "\t")
+	case "csv":
+		var builder strings.Builder
+		writer := csv.NewWriter(&builder)
+		writer.Write(fields)
+		writer.Flush()
+		return strings.TrimSuffix(builder.String(), "\n")
+	}
+
+	data, _ := json.Marshal(record)
+	return string(data)
+}
+
+// normalizeOutput runs every line of content through parser and returns the formatted records,
+// one per line
+func normalizeOutput(content string, parser OutputParser, format string) string {
+	var builder strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if record, ok := parser(line); ok {
+			builder.WriteString(formatRecord(record, format))
+			builder.WriteString("\n")
+		}
+	}
+	return builder.String()
+}
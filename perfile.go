@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// findInputFiles lists the files under dir, in subdirectories too, whose name matches pattern,
+// in sorted path order
+func findInputFiles(dir, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid input pattern %s: %w", pattern, err)
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if matched, _ := filepath.Match(pattern, entry.Name()); matched {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input directory: %w", err)
+	}
+	return files, nil
+}
+
+// runPerFile runs the tool on every file of config.InputDir separately, writing each file's
+// results under outputDir at the same relative path (targets/a/x.txt -> out/a/x.txt), so file
+// boundaries survive. Files run one after another, each with all of config.Workers. A run that
+// is stopped (Ctrl-C, or a failed task without --collect-errors) stops the remaining files.
+func runPerFile(config RunnerConfig, outputDir string) error {
+	pattern := config.InputPattern
+	if pattern == "" {
+		pattern = "*.txt"
+	}
+	files, err := findInputFiles(config.InputDir, pattern)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		if config.AllowEmptyInput {
+			LogWarn("No files matching %s found in %s", pattern, config.InputDir)
+			return nil
+		}
+		return fmt.Errorf("no files matching %s found in %s (pass --allow-empty-input to accept this)", pattern, config.InputDir)
+	}
+	LogInfo("Processing %d files from %s separately into %s", len(files), config.InputDir, outputDir)
+
+	// --require-output applies to the whole run, not to each file
+	inputDir, requireOutput := config.InputDir, config.RequireOutput
+	config.RequireOutput = false
+	config.InputDir = ""
+	// One empty file among many is not worth failing the run
+	config.AllowEmptyInput = true
+
+	processed, withOutput, withFailures := 0, 0, 0
+	for i, file := range files {
+		rel, err := filepath.Rel(inputDir, file)
+		if err != nil {
+			rel = filepath.Base(file)
+		}
+		fileConfig := config
+		fileConfig.InputFile = file
+		fileConfig.OutputFile = filepath.Join(outputDir, rel)
+
+		LogInfo("File %d/%d: %s -> %s", i+1, len(files), file, fileConfig.OutputFile)
+		runner, err := NewRunner(fileConfig)
+		if err != nil {
+			return err
+		}
+		if err := runner.Run(); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		processed++
+		if runner.FailedCount() > 0 || runner.unfinishedCount() > 0 {
+			withFailures++
+		}
+		if runner.producedOutput() {
+			withOutput++
+		}
+		if runner.cancelled() {
+			LogWarn("Stopped after %d of %d files", i+1, len(files))
+			break
+		}
+	}
+
+	LogInfo("Processed %d files: %d with results, %d with failed or unfinished tasks", processed, withOutput, withFailures)
+	if requireOutput && withOutput == 0 {
+		return fmt.Errorf("no output was produced (--require-output)")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// parseFileMode parses an octal permission string such as "0640" or "750", as used by
+// --output-mode, --dir-mode and --umask
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %q, expected octal permissions such as 0640", value)
+	}
+	if mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q, only permission bits (up to 0777) are allowed", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// createFileMode creates (or truncates) path like os.Create. A non-zero mode is set exactly,
+// regardless of the umask, so an explicit --output-mode is what ends up on disk.
+func createFileMode(path string, mode os.FileMode) (*os.File, error) {
+	return openFileMode(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+}
+
+// appendFileMode opens path for appending, creating it if needed, with the same mode handling as
+// createFileMode
+func appendFileMode(path string, mode os.FileMode) (*os.File, error) {
+	return openFileMode(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, mode)
+}
+
+func openFileMode(path string, flag int, mode os.FileMode) (*os.File, error) {
+	if mode == 0 {
+		return os.OpenFile(path, flag, 0666)
+	}
+	file, err := os.OpenFile(path, flag, mode)
+	if err != nil {
+		return nil, err
+	}
+	// OpenFile only applies the mode to new files, and through the umask
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// mkdirAllMode creates dir and any missing parents with mode. Directories created here get
+// mode exactly (not masked by the umask); existing ones are left alone.
+func mkdirAllMode(dir string, mode os.FileMode) error {
+	var created []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		created = append(created, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	for _, d := range created {
+		if err := os.Chmod(d, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
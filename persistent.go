@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxPersistentRestarts is how many times one worker's process is restarted before the run is stopped
+const maxPersistentRestarts = 3
+
+// persistentProcess is one long-lived tool process fed by a worker in persistent mode
+type persistentProcess struct {
+	worker  int
+	stdin   io.WriteCloser
+	writer  *bufio.Writer
+	readers sync.WaitGroup // stdout and stderr readers
+	done    chan struct{}  // Closed once the process has exited
+	wait    func() error
+	fed     []int // Task indexes written to this process; their lines may be lost if it dies
+}
+
+// runTasksPersistent starts Workers long-lived processes and feeds tasks to whichever worker is
+// free, like the dynamic scheduler. A task counts as completed once its lines are written to a
+// process's stdin; if that process later dies or exits with an error, its fed tasks are marked failed.
+func (r *Runner) runTasksPersistent() error {
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < r.config.Workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			r.runPersistentWorker(worker, queue)
+		}(w)
+	}
+
+	for i := range r.tasks {
+		select {
+		case <-r.cancelChan:
+			LogWarn("Scheduler cancelled, %d tasks not started.", len(r.tasks)-i)
+			close(queue)
+			wg.Wait()
+			return nil
+		case queue <- i:
+		}
+	}
+	close(queue)
+
+	wg.Wait()
+	return nil
+}
+
+// runPersistentWorker owns one tool process, writing each task it pulls from queue to its stdin
+// and restarting the process if it dies. It closes stdin and waits for the process when the queue is done.
+func (r *Runner) runPersistentWorker(worker int, queue <-chan int) {
+	if !r.sleepStartDelay(worker) {
+		return // Cancelled; the scheduler stops feeding the queue too
+	}
+
+	proc, err := r.startPersistentProcess(worker)
+	if err != nil {
+		LogError("Failed to start persistent process for worker %d: %v", worker, err)
+		r.cancelTasks()
+		return
+	}
+
+	restarts := 0
+	for taskIndex := range queue {
+		r.mu.Lock()
+		task := &r.tasks[taskIndex]
+		task.Status = TaskRunning
+		task.StartTime = time.Now()
+		r.mu.Unlock()
+
+		lines := r.taskInputLines(task)
+		err := proc.feed(lines)
+		for err != nil {
+			// Writing failed because the process is gone: fail what it had accepted and start a new one
+			exitErr := proc.finish()
+			if exitErr == nil {
+				exitErr = fmt.Errorf("persistent process exited before reading all input")
+			}
+			LogWarn("Persistent process for worker %d died: %v", worker, exitErr)
+			r.failFedTasks(proc, exitErr)
+			if restarts >= maxPersistentRestarts {
+				LogError("Worker %d process died %d times, stopping the run", worker, restarts+1)
+				r.failTask(taskIndex, nil, fmt.Errorf("persistent process died %d times: %w", restarts+1, exitErr), nil)
+				r.cancelTasks()
+				return
+			}
+			restarts++
+			LogWarn("Restarting persistent process for worker %d (%d/%d)", worker, restarts, maxPersistentRestarts)
+			if proc, err = r.startPersistentProcess(worker); err != nil {
+				LogError("Failed to restart persistent process for worker %d: %v", worker, err)
+				r.failTask(taskIndex, nil, fmt.Errorf("failed to restart persistent process: %w", err), nil)
+				r.cancelTasks()
+				return
+			}
+			err = proc.feed(lines)
+		}
+
+		proc.fed = append(proc.fed, taskIndex)
+		LogTask(task.ID, "fed %d lines to worker %d", len(lines), worker)
+		r.updateTaskStatus(taskIndex, TaskCompleted)
+	}
+
+	if err := proc.finish(); err != nil {
+		select {
+		case <-r.cancelChan:
+			// Killed by cancellation, already reported
+		default:
+			LogError("Persistent process for worker %d failed: %v", worker, err)
+			r.failFedTasks(proc, err)
+		}
+	}
+}
+
+// startPersistentProcess starts the tool for worker with stdin, stdout and stderr attached
+func (r *Runner) startPersistentProcess(worker int) (*persistentProcess, error) {
+	cmdParts, err := r.configManager.BuildCommand(r.config.Command, "", "", r.config.CommandArgs, "", r.config.Wordlist, r.shell())
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := r.newToolCommand(cmdParts, &Task{ID: worker, WindowName: fmt.Sprintf("persistent_%d", worker)})
+	if err != nil {
+		return nil, err
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	LogInfo("Worker %d started persistent process (PID: %d)", worker, cmd.Process.Pid)
+
+	proc := &persistentProcess{
+		worker: worker,
+		stdin:  stdin,
+		writer: bufio.NewWriter(stdin),
+		done:   make(chan struct{}),
+		wait: func() error {
+			defer r.untrackProcess(cmd)
+			return cmd.Wait()
+		},
+	}
+
+	proc.readers.Add(2)
+	go func() {
+		defer proc.readers.Done()
+		scanner := r.lineScanner(stdout)
+		for scanner.Scan() {
+			r.writeToOutput(scanner.Text() + "\n")
+		}
+	}()
+	go func() {
+		defer proc.readers.Done()
+		scanner := r.lineScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			LogInfo("[worker %d] [STDERR] %s", worker, line)
+			if r.toolConfig.CaptureStderr {
+				r.writeToOutput(line + "\n")
+			}
+		}
+	}()
+
+	// Stop the process group on cancellation, as for per-task processes
+	r.trackProcess(cmd)
+	go func() {
+		select {
+		case <-r.cancelChan:
+			LogWarn("Stopping persistent process %d for worker %d due to cancellation", cmd.Process.Pid, worker)
+			r.stopProcess(cmd, proc.done)
+		case <-proc.done:
+		}
+	}()
+
+	return proc, nil
+}
+
+// feed writes lines to the process's stdin and flushes them
+func (p *persistentProcess) feed(lines []string) error {
+	for _, line := range lines {
+		if _, err := p.writer.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	return p.writer.Flush()
+}
+
+// finish closes stdin so the tool sees end of input, drains its output and waits for it to exit
+func (p *persistentProcess) finish() error {
+	p.writer.Flush()
+	p.stdin.Close()
+	p.readers.Wait()
+	err := p.wait()
+	close(p.done)
+	return err
+}
+
+// failFedTasks marks every task already written to proc as failed, since the dead process may
+// not have produced their results
+func (r *Runner) failFedTasks(proc *persistentProcess, err error) {
+	for _, taskIndex := range proc.fed {
+		r.mu.RLock()
+		task := &r.tasks[taskIndex]
+		r.mu.RUnlock()
+		LogTask(task.ID, "results may be incomplete: %v", err)
+		r.failTask(taskIndex, nil, err, nil)
+	}
+	proc.fed = nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// absPath is path made absolute for messages, or path itself if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// checkReadableFile fails with a clear message if path, described by what (e.g. "input file"),
+// does not exist, is a directory or cannot be opened for reading
+func checkReadableFile(what, path string) error {
+	file, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%s %s does not exist", what, absPath(path))
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%s %s is not readable; check its permissions", what, absPath(path))
+	case err != nil:
+		return fmt.Errorf("cannot open %s %s: %w", what, absPath(path), err)
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.IsDir() {
+		return fmt.Errorf("%s %s is a directory", what, absPath(path))
+	}
+	return nil
+}
+
+// checkReadableDir fails with a clear message if dir does not exist or cannot be listed
+func checkReadableDir(what, dir string) error {
+	info, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%s %s does not exist", what, absPath(dir))
+	case err != nil:
+		return fmt.Errorf("cannot read %s %s: %w", what, absPath(dir), err)
+	case !info.IsDir():
+		return fmt.Errorf("%s %s is not a directory", what, absPath(dir))
+	}
+	if _, err := os.ReadDir(dir); err != nil {
+		return fmt.Errorf("%s %s is not readable; check its permissions", what, absPath(dir))
+	}
+	return nil
+}
+
+// checkOutputDir fails with a clear message if the output cannot be created in dir. A dir that
+// does not exist yet is checked at its closest existing parent, where it will be created.
+func checkOutputDir(dir string) error {
+	existing := filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	// Permission bits do not tell the whole story (ACLs, read-only mounts), so try it
+	if err := checkWritableDir(existing); err != nil {
+		reason := err.Error()
+		if errors.Is(err, fs.ErrPermission) {
+			reason = "permission denied"
+		}
+		if existing != filepath.Clean(dir) {
+			return fmt.Errorf("cannot create output directory %s: %s is not writable (%s)", absPath(dir), absPath(existing), reason)
+		}
+		return fmt.Errorf("output directory %s is not writable (%s); choose another --output or fix its permissions", absPath(dir), reason)
+	}
+	return nil
+}
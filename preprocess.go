@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// preprocessLines runs the tool's preprocess command over input lines and returns its stdout
+// lines as the new input. By default the command runs once with all lines on stdin; with
+// preprocess_per_line it runs once per line with {input} replaced by the line, and the
+// output of every run is concatenated. Any failure is returned so the run stops before tasks
+// start, rather than silently scanning a partial input.
+func (r *Runner) preprocessLines(lines []string) ([]string, error) {
+	if !r.toolConfig.PreprocessPerLine {
+		stdin := strings.Join(lines, "\n") + "\n"
+		return r.runPreprocess(r.toolConfig.Preprocess, stdin)
+	}
+
+	var result []string
+	for _, line := range lines {
+		command := strings.ReplaceAll(r.toolConfig.Preprocess, "{input}", shellQuote(r.shell(), line))
+		out, err := r.runPreprocess(command, "")
+		if err != nil {
+			return nil, fmt.Errorf("line %q: %w", line, err)
+		}
+		result = append(result, out...)
+	}
+	return result, nil
+}
+
+// runPreprocess runs command through the shell with stdin and splits its stdout into lines
+func (r *Runner) runPreprocess(command string, stdin string) ([]string, error) {
+	shell := r.shell()
+	cmd := exec.Command(shell, shellCommandFlag(shell), command)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("preprocess command failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("preprocess command failed: %w", err)
+	}
+
+	text := strings.TrimRight(strings.ReplaceAll(string(out), "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
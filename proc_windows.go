@@ -0,0 +1,24 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess kills the command's process: Windows has no SIGTERM to deliver to a
+// process without a console, so there is no graceful phase
+func terminateProcess(cmd *exec.Cmd) error {
+	return killProcess(cmd)
+}
+
+// killProcess kills the command's process
+func killProcess(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// remoteShell is the shell the command is written for with --remote: the remote login shell runs
+// the task script, which is plain POSIX sh
+const remoteShell = "sh"
+
+// validateRemote rejects tool settings that cannot work when tasks run over SSH
+func validateRemote(name string, toolConfig ToolConfig) error {
+	switch {
+	case toolConfig.Persistent:
+		return fmt.Errorf("persistent tool '%s' cannot run with --remote", name)
+	case toolConfig.SplitWordlist:
+		return fmt.Errorf("tool '%s' uses split_wordlist, whose wordlist chunks are not sent to the remote host", name)
+	case toolConfig.CombineOutput && !toolConfig.UseStdout:
+		return fmt.Errorf("tool '%s' cannot combine combine_output with --remote unless use_stdout is set", name)
+	}
+	return nil
+}
+
+// remoteScript turns a task's command into a script for the remote host. It runs in a fresh temp
+// directory, so the relative chunk and temp output names in the command work unchanged:
+//   - the task's input arrives on stdin and is saved as upload (the chunk file in multiple mode,
+//     or what stdin input mode feeds the tool);
+//   - the tool's environment is exported, since SSH does not forward it;
+//   - for tools that write {output}, the tool's stdout goes to stderr and the output file is
+//     printed on stdout afterwards, to be saved as the local temp output;
+//   - the directory is removed and the tool's exit status returned.
+func (r *Runner) remoteScript(cmdParts []string, task *Task, upload, tempOutputFile string) []string {
+	command := strings.Join(cmdParts, " ")
+	if r.toolConfig.DirectExec {
+		quoted := make([]string, len(cmdParts))
+		for i, part := range cmdParts {
+			quoted[i] = shellQuote(remoteShell, part)
+		}
+		command = strings.Join(quoted, " ")
+	}
+
+	setup := `d=$(mktemp -d) && cd "$d"`
+	if upload != "" {
+		setup += " && cat > " + shellQuote(remoteShell, upload)
+	}
+	steps := []string{setup + " || exit 1"}
+	for _, entry := range r.taskEnv(task) {
+		steps = append(steps, "export "+shellQuote(remoteShell, entry))
+	}
+	if r.toolConfig.NoGlob || r.config.NoGlob {
+		steps = append(steps, "set -f")
+	}
+
+	run := "{ " + command + "; }"
+	if r.toolConfig.InputMode == InputModeStdin {
+		run += " < " + shellQuote(remoteShell, upload)
+	}
+	if !r.toolConfig.UseStdout {
+		// With capture_stderr, progress on stdout would otherwise end up in the results
+		if r.toolConfig.CaptureStderr {
+			run += " > /dev/null"
+		} else {
+			run += " >&2"
+		}
+	}
+	steps = append(steps, run, "rc=$?")
+	if !r.toolConfig.UseStdout {
+		steps = append(steps, "cat "+shellQuote(remoteShell, tempOutputFile)+" 2>/dev/null")
+	}
+	steps = append(steps, `cd / && rm -rf "$d"`, "exit $rc")
+	return []string{strings.Join(steps, "; ")}
+}
+
+// remoteUploadName is the file the remote script saves the task's stdin as: the chunk file in
+// multiple mode, a file of its own for stdin input mode, or nothing when the command needs neither
+func (r *Runner) remoteUploadName(task *Task, chunkFile string) string {
+	if chunkFile != "" {
+		return chunkFile
+	}
+	if r.toolConfig.InputMode == InputModeStdin {
+		return r.tempFileName("stdin", task.ID)
+	}
+	return ""
+}
+
+// newRemoteCommand runs a script made by remoteScript on the --remote host. BatchMode makes
+// SSH fail instead of waiting for a password, so an unreachable host fails the task.
+func (r *Runner) newRemoteCommand(cmdParts []string) *exec.Cmd {
+	script := strings.Join(cmdParts, " ")
+	LogInfo("Running command on %s: %s", r.config.Remote, script)
+	return exec.Command("ssh", "-o", "BatchMode=yes", r.config.Remote, script)
+}
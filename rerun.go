@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var rerunCmd = &cobra.Command{
+	Use:   "rerun [report.json]",
+	Short: "Run the failed tasks of an --errors-file report again",
+	Long: `Reads a failed-task report written with --errors-file and runs only the input of the failed
+tasks again, with the same tool, config, arguments and wordlist. New results are appended to the
+original output file, so a flaky scan can be completed without starting over.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runRerun,
+}
+
+func init() {
+	rootCmd.AddCommand(rerunCmd)
+
+	rerunCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Append results to this file instead of the output of the reported run")
+	rerunCmd.Flags().IntVarP(&workers, "threads", "t", 4, "Number of parallel threads")
+	rerunCmd.Flags().StringVarP(&configFile, "config", "c", "", "Config file to use instead of the one of the reported run")
+	rerunCmd.Flags().StringVar(&shellName, "shell", "", "Shell used to run tool commands (default: $BULKER_SHELL, then bash or sh; cmd on Windows)")
+	rerunCmd.Flags().BoolVar(&collectErrs, "collect-errors", false, "Keep running when a task fails instead of stopping the run (default: stop on the first failure)")
+	rerunCmd.Flags().StringVar(&errorsFile, "errors-file", "", "Write the tasks that fail again to this file, ready for another rerun (it may be the report itself)")
+	rerunCmd.Flags().IntVar(&stderrTail, "stderr-tail", 10, "Number of trailing stderr lines kept per task for the failed-task report (0 keeps none)")
+}
+
+func runRerun(cmd *cobra.Command, args []string) {
+	report := args[0]
+	if err := checkReadableFile("report", report); err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+	taskErrors, err := readTaskErrors(report)
+	if err != nil {
+		LogError("Error reading report: %v", err)
+		os.Exit(1)
+	}
+	if len(taskErrors) == 0 {
+		LogSuccess("%s lists no failed tasks, nothing to rerun", report)
+		return
+	}
+	config, lines, err := rerunConfig(taskErrors)
+	if err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+
+	if outputFile != "" {
+		config.OutputFile = outputFile
+	}
+	if configFile != "" {
+		config.ConfigFile = configFile
+	}
+	if config.OutputFile == "" {
+		LogError("Error: the report does not name an output file (the run wrote to stdout); pass --output")
+		os.Exit(1)
+	}
+	config.Shell, err = resolveShell(shellName)
+	if err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+	config.Workers = workers
+	config.CollectErrors = collectErrs
+	config.ErrorsFile = errorsFile
+	config.StderrTail = stderrTail
+
+	// The failed input is written out like a watch batch, so it is read like any input file
+	batch, err := os.CreateTemp("", "bulker_rerun_*.txt")
+	if err != nil {
+		LogError("Error creating temp file: %v", err)
+		os.Exit(1)
+	}
+	defer os.Remove(batch.Name())
+	_, err = batch.WriteString(strings.Join(lines, "\n") + "\n")
+	if closeErr := batch.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		LogError("Error writing temp file: %v", err)
+		os.Exit(1)
+	}
+	config.InputFile = batch.Name()
+
+	LogInfo("Rerunning %d failed tasks (%d input lines) of %s, appending to %s", len(taskErrors), len(lines), config.Command, config.OutputFile)
+
+	runner, err := NewRunner(config)
+	if err != nil {
+		LogError("Error creating runner: %v", err)
+		os.Exit(1)
+	}
+	if err := runner.Run(); err != nil {
+		LogError("Error: %v", err)
+		os.Exit(1)
+	}
+	if failed, unfinished := runner.FailedCount(), runner.unfinishedCount(); failed > 0 || unfinished > 0 {
+		LogError("Rerun finished with %d failed and %d unfinished tasks", failed, unfinished)
+		os.Exit(1)
+	}
+	LogSuccess("All failed tasks of %s now succeeded", report)
+}
+
+// readTaskErrors reads a report written by writeTaskErrors
+func readTaskErrors(path string) ([]TaskError, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var taskErrors []TaskError
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), defaultMaxLineSize)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var taskErr TaskError
+		if err := json.Unmarshal([]byte(line), &taskErr); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		taskErrors = append(taskErrors, taskErr)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return taskErrors, nil
+}
+
+// rerunConfig rebuilds the settings of the reported run and returns them with the input lines of
+// all failed tasks, each once. Settings that only shaped the input (filters, input_format) are
+// not carried over: the report holds the lines as the tasks received them.
+func rerunConfig(taskErrors []TaskError) (RunnerConfig, []string, error) {
+	if len(taskErrors) == 0 {
+		return RunnerConfig{}, nil, fmt.Errorf("the report lists no failed tasks")
+	}
+	first := taskErrors[0]
+	if first.Tool == "" {
+		return RunnerConfig{}, nil, fmt.Errorf("the report does not name a tool; it was written by a --raw run or an older bulker")
+	}
+
+	seen := make(map[string]struct{})
+	var lines []string
+	for _, taskErr := range taskErrors {
+		if taskErr.Tool != first.Tool || taskErr.Output != first.Output {
+			return RunnerConfig{}, nil, fmt.Errorf("task %d was run by a different tool or into a different output than task %d", taskErr.TaskID, first.TaskID)
+		}
+		for _, line := range taskErr.Input {
+			if _, dup := seen[line]; dup {
+				continue
+			}
+			seen[line] = struct{}{}
+			lines = append(lines, line)
+		}
+	}
+
+	return RunnerConfig{
+		OutputFile:   first.Output,
+		AppendOutput: true,
+		Command:      first.Tool,
+		CommandArgs:  first.Args,
+		ConfigFile:   first.Config,
+		Profile:      first.Profile,
+		Mode:         first.Mode,
+		Wordlist:     first.Wordlist,
+		Scheduler:    "static",
+		LineEnding:   LineEndingLF,
+		// The lines were already filtered once; a line that looks like a comment is real input
+		NoComments: true,
+	}, lines, nil
+}
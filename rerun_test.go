@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTaskErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.jsonl")
+	written := []TaskError{
+		{TaskID: 1, Input: []string{"a", "b"}, Tool: "httpx", Output: "/out.txt", Args: []string{"-silent"}},
+		{TaskID: 4, Input: []string{"c"}, Tool: "httpx", Output: "/out.txt"},
+	}
+	if err := writeTaskErrors(path, written); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readTaskErrors(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, written) {
+		t.Errorf("readTaskErrors = %+v, want %+v", got, written)
+	}
+}
+
+func TestReadTaskErrorsEmptyAndInvalid(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.jsonl")
+	if err := writeTaskErrors(empty, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readTaskErrors(empty); err != nil || len(got) != 0 {
+		t.Errorf("readTaskErrors(empty) = %v, %v, want no records", got, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.jsonl")
+	os.WriteFile(invalid, []byte("{\"task_id\":1}\nnot json\n"), 0644)
+	if _, err := readTaskErrors(invalid); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("readTaskErrors(invalid) = %v, want an error on line 2", err)
+	}
+}
+
+func TestRerunConfig(t *testing.T) {
+	taskErrors := []TaskError{
+		{TaskID: 0, Input: []string{"a", "b"}, Tool: "nuclei", Config: "/bulker.toml", Output: "/out.txt", Args: []string{"-severity", "high"}, Wordlist: "/words.txt"},
+		{TaskID: 3, Input: []string{"b", "c", "a"}, Tool: "nuclei", Config: "/bulker.toml", Output: "/out.txt"},
+	}
+
+	config, lines, err := rerunConfig(taskErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q (each once, in report order)", lines, want)
+	}
+	if config.Command != "nuclei" || config.ConfigFile != "/bulker.toml" || config.OutputFile != "/out.txt" || config.Wordlist != "/words.txt" {
+		t.Errorf("config = %+v, want the settings of the first task", config)
+	}
+	if !reflect.DeepEqual(config.CommandArgs, []string{"-severity", "high"}) {
+		t.Errorf("CommandArgs = %q", config.CommandArgs)
+	}
+	if !config.AppendOutput {
+		t.Error("AppendOutput is false, the rerun would replace the output")
+	}
+}
+
+func TestRerunConfigRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		taskErrors []TaskError
+		want       string
+	}{
+		{"empty report", nil, "no failed tasks"},
+		{"no tool", []TaskError{{TaskID: 1, Input: []string{"a"}}}, "does not name a tool"},
+		{"mixed tools", []TaskError{
+			{TaskID: 1, Input: []string{"a"}, Tool: "httpx", Output: "/out.txt"},
+			{TaskID: 2, Input: []string{"b"}, Tool: "nuclei", Output: "/out.txt"},
+		}, "different tool"},
+		{"mixed outputs", []TaskError{
+			{TaskID: 1, Input: []string{"a"}, Tool: "httpx", Output: "/a.txt"},
+			{TaskID: 2, Input: []string{"b"}, Tool: "httpx", Output: "/b.txt"},
+		}, "different output"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := rerunConfig(tt.taskErrors); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("rerunConfig = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportIncludesUnfinishedTasks(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "failed.jsonl")
+	r := &Runner{
+		config:     RunnerConfig{Command: "httpx", ErrorsFile: report},
+		toolConfig: ToolConfig{Mode: "single"},
+		outputPath: filepath.Join(dir, "out.txt"),
+		inputLines: []string{"a", "b", "c", "d"},
+		tasks: []Task{
+			{ID: 0, Status: TaskCompleted},
+			{ID: 1, Status: TaskFailed},
+			{ID: 2, Status: TaskRunning},
+			{ID: 3, Status: TaskPending},
+		},
+	}
+	r.recordTaskError(&r.tasks[1], []string{"httpx"}, os.ErrDeadlineExceeded, nil)
+	r.reportTaskErrors()
+
+	taskErrors, err := readTaskErrors(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, lines, err := rerunConfig(taskErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c", "d"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("rerun input = %q, want the failed and the unfinished lines %q", lines, want)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// resizeSignals are the signals that change the worker count while tasks run:
+// SIGUSR1 adds a worker, SIGUSR2 removes one
+var resizeSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2}
+
+// resizeDelta is the change in workers requested by sig
+func resizeDelta(sig os.Signal) int {
+	if sig == syscall.SIGUSR2 {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// resizeSignals is empty: Windows has no SIGUSR1/SIGUSR2, so the worker count is fixed there
+var resizeSignals []os.Signal
+
+func resizeDelta(sig os.Signal) int {
+	return 0
+}
@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package main
+
+// openFileLimit reports no limit: this platform has no ulimit on open files
+func openFileLimit() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,14 @@
+//go:build !windows && !plan9
+
+package main
+
+import "syscall"
+
+// openFileLimit returns the soft limit on open files (ulimit -n) of the process
+func openFileLimit() (uint64, bool) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, false
+	}
+	return uint64(limit.Cur), true
+}
@@ -2,15 +2,28 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 type RunnerConfig struct {
@@ -21,6 +34,144 @@ type RunnerConfig struct {
 	CommandArgs []string
 	ConfigFile  string
 	Wordlist    string
+	// Profile selects a [profiles.<name>] section of the config file (see NewConfigManager).
+	Profile string
+	// SplitBytes, when greater than zero, splits input into chunks of at most this many bytes
+	// instead of dividing lines evenly across workers (multiple mode only).
+	SplitBytes int64
+	// Scheduler selects how tasks are handed to workers: "static" (default) or "dynamic".
+	Scheduler string
+	// Sample, between 0 and 1, keeps only that random fraction of the input lines (after the
+	// other input filters), picked with SampleSeed; 0 keeps every line.
+	Sample     float64
+	SampleSeed int64
+	// SafeOutput moves the backed-up output file back in place if the run fails, or none of its
+	// tasks succeed; see restoreBackup.
+	SafeOutput bool
+	// AllowEmptyInput lets a run whose input has no lines at all finish without tasks instead of
+	// failing. Input whose lines are all filtered out (comments, --since, sharding) never fails.
+	AllowEmptyInput bool
+	// PriorityFile lists input lines to move to the front of the input.
+	PriorityFile string
+	// InputDir, when set, reads all files matching InputPattern in this directory as the input.
+	InputDir     string
+	InputPattern string
+	// InputFormat is "line" (default), "json" (one JSON object per line, value at JSONField, a dotted
+	// path) or "csv" (value in CSVColumn, a header name or a 1-based index).
+	InputFormat string
+	JSONField   string
+	CSVColumn   string
+	// Since, when set, keeps only input lines whose timestamp (captured by TimeRegex, parsed with
+	// the Go layout TimeFormat) is after it. It is a time in TimeFormat or a duration back from now.
+	Since      string
+	TimeRegex  string
+	TimeFormat string
+	// TagSource prefixes each line read from InputDir with "<filename>:".
+	TagSource bool
+	// LineTimeout, when greater than zero, kills the process for a single-mode line that runs longer.
+	LineTimeout time.Duration
+	// DedupInput drops repeated input lines, keeping the first occurrence.
+	DedupInput bool
+	// SkipBinary drops input lines holding a NUL byte or invalid UTF-8. Without it a NUL byte
+	// fails the run and invalid UTF-8 is passed on with a warning.
+	SkipBinary bool
+	// Strict makes an input line that does not match the tool's input_pattern an error instead of
+	// skipping it.
+	Strict bool
+	// Shell is the shell used to run tool commands (e.g. bash, sh, zsh, pwsh, cmd)
+	Shell string
+	// AppendNewline makes every merged task block end with exactly one newline.
+	AppendNewline bool
+	// LineEnding is the line ending every line of the output is written with: LineEndingLF,
+	// LineEndingCRLF, or LineEndingKeep to write tool output as produced.
+	LineEnding string
+	// MemLimit, when greater than zero, is a memory budget in bytes; concurrency is reduced while it is exceeded.
+	MemLimit int64
+	// MaxRuntime, when greater than zero, stops the whole run after this long and keeps partial results.
+	MaxRuntime time.Duration
+	// Targets, when set, is used as the input instead of InputFile or stdin.
+	Targets []string
+	// StripANSI removes ANSI color codes from tool output before it is written to the output file.
+	StripANSI bool
+	// OutputFormat selects how parsed records are written: "text" (tool's parser_format), "json", "csv"
+	// or "tsv". "task-json" instead writes one JSON object per task with all of its output.
+	OutputFormat string
+	// Compress gzips the output file.
+	Compress bool
+	// Tail echoes everything written to the output file to stdout as well.
+	Tail bool
+	// Mode, when set, overrides the tool's configured mode ("single" or "multiple").
+	Mode string
+	// RawTool, when set, is the tool to run instead of looking Command up in the config file (--raw).
+	RawTool *ToolConfig
+	// NoGlob turns off shell globbing for the tool's command, like the tool's no_glob setting.
+	NoGlob bool
+	// CountOnly counts result lines instead of writing them; no output file is created.
+	CountOnly bool
+	// Remote, when set (user@host), runs every task on that host over SSH; see remoteScript.
+	Remote string
+	// AppendOutput adds to an existing output file instead of backing it up and starting a new one;
+	// the header is only written to an empty file. Used for the batches of --watch.
+	AppendOutput bool
+	// SkipLines holds input lines that were already processed (by earlier --watch batches); they
+	// are dropped like duplicates.
+	SkipLines map[string]struct{}
+	// MaxLineSize is the longest line, in bytes, read from the input, wordlist and tool output;
+	// 0 uses defaultMaxLineSize.
+	MaxLineSize int
+	// RequireOutput makes Run return an error when no results were produced (a header alone does not count).
+	RequireOutput bool
+	// ShardIndex and ShardCount keep only input lines whose FNV-1a hash mod ShardCount equals ShardIndex.
+	ShardIndex int
+	ShardCount int
+	// Env holds extra KEY=VALUE environment entries for every task, applied after the tool's env table.
+	Env []string
+	// ProgressInterval is how often progress is logged while tasks run; 0 disables progress logging.
+	ProgressInterval time.Duration
+	// Checksum logs the SHA-256 of the output file once the run has finished writing it.
+	Checksum bool
+	// ChecksumFile also writes the checksum to a "<output>.sha256" sidecar file.
+	ChecksumFile bool
+	// MaxOutputSize, when greater than zero, caps the bytes written to one output file. When it
+	// would be exceeded the run stops, or with Rotate continues in a new numbered file.
+	MaxOutputSize int64
+	Rotate        bool
+	// OutputSplit, when greater than zero, writes the output as numbered parts (out.part1.txt,
+	// out.part2.txt, ...) of at most this many lines each, not counting the header every part starts with.
+	OutputSplit int
+	// OutputMode and DirMode, when non-zero, are the exact permissions given to the output file(s)
+	// and to output directories bulker creates. Zero keeps the defaults (0666 and 0755, less the umask).
+	OutputMode os.FileMode
+	DirMode    os.FileMode
+	// OutputBuffer is how many output blocks can be queued for the writer; when the queue is full,
+	// tasks wait for the writer instead of holding more output in memory. 0 uses the default.
+	OutputBuffer int
+	// FlushInterval is how often new output is flushed and synced to disk, which bounds what a
+	// crash can lose. 0 uses defaultFlushInterval.
+	FlushInterval time.Duration
+	// WorkerStartDelay staggers the start of the run: worker n starts n delays after the first.
+	WorkerStartDelay time.Duration
+	// ShutdownTimeout is how long cancelled tasks get to exit after SIGTERM before they are killed.
+	ShutdownTimeout time.Duration
+	// KeepTemp leaves chunk, wordlist chunk and temp output files on disk for debugging.
+	KeepTemp bool
+	// NoComments keeps input lines starting with the tool's comment prefix instead of skipping them.
+	NoComments bool
+	// CollectErrors keeps the run going when a task fails instead of cancelling the remaining tasks.
+	CollectErrors bool
+	// StderrTail is how many trailing stderr lines are kept per task for failure reports; 0 keeps none.
+	StderrTail int
+	// ErrorsFile, when set, receives the failed-task report as JSON lines.
+	ErrorsFile string
+	// FailedChunksDir, when set, receives the input lines of each failed task as task_<id>.txt.
+	FailedChunksDir string
+	// NotifyURL, when set, receives a JSON summary of the run when it ends.
+	NotifyURL string
+	// MetricsFile, when set, receives the run summary in the Prometheus textfile collector format
+	// when the run ends.
+	MetricsFile string
+	// WindowNameFormat names tasks in logs; {id} is replaced with the zero-padded task ID.
+	WindowNameFormat string
 }
 
 type Runner struct {
@@ -28,14 +179,52 @@ type Runner struct {
 	signalHandler *SignalHandler
 	configManager *ConfigManager
 	toolConfig    ToolConfig
+	maxWorkers    int    // Highest worker count a resize may set: max_workers within the open file limit; 0 means no cap
+	runID         string // Identifies this run in logs, reports and temp file names
+	outputParser  OutputParser
 	tasks         []Task
 	mu            sync.RWMutex
 	outputFile    *os.File
-	outputMutex   sync.Mutex
+	outputGzip    *gzip.Writer  // Wraps outputFile when Compress is set
+	outputWriter  *bufio.Writer // Buffers writes to outputGzip or outputFile
+	outputChan    chan string   // Content queued for the writer goroutine
+	outputDone    chan struct{} // Closed when the writer goroutine has closed the file
+	outputClosed  bool          // Set by closeOutputFile; guarded by outputMutex
+	outputMutex   sync.RWMutex
 	outputPath    string
-	inputLines    []string // Store input lines directly
+	outputBytes   int64        // Bytes written to the current output file (before compression)
+	bytesWritten  atomic.Int64 // Bytes written to all output files (before compression)
+	resultCount   atomic.Int64 // Result lines counted with CountOnly
+	resultBytes   atomic.Int64 // Bytes of results written, not counting headers
+	headerBytes   int64        // Part of outputBytes taken by the header
+	outputPart    int          // Number of the current rotated output file or split part; 0 is outputPath itself
+	outputLines   int          // Result lines in the current output file, for OutputSplit
+	outputDirty   bool         // Output was written since the last sync; writer goroutine only
+	backupPath    string       // Where an existing output file was moved at the start of the run, if it was
+	outputFull    bool         // Set once --max-output-size is reached without --rotate
+	stdoutClosed  sync.Once    // Stops the run once when the reader of --output - goes away
+	inputLines    []string     // Store input lines directly
+	readCount     int          // Non-empty lines read from the input, before any filter
+	wordlistLines []string     // Wordlist lines, only loaded for split_wordlist tools
+	seenLines     map[string]struct{}
+	dupCount      int
+	skippedDone   int // Lines dropped because they are in SkipLines
+	shardSkipped  int
+	commentPrefix string // Input lines starting with this are skipped; empty keeps every line
+	commentCount  int
+	inputPattern  *regexp.Regexp // Input lines must match this (input_pattern); nil keeps every line
+	invalidCount  int            // Lines dropped by inputPattern
+	binaryCount   int            // Lines dropped by SkipBinary
+	nonUTF8Count  int            // Lines kept although they are not valid UTF-8
+	sinceFilter   *sinceFilter   // Drops input lines older than --since; nil keeps every line
+	oldCount      int            // Lines dropped by sinceFilter
+	undatedCount  int            // Of those, lines without a parseable timestamp
 	cancelChan    chan struct{}
 	cancelOnce    sync.Once
+	processes     map[*exec.Cmd]struct{} // Running tool processes, killed if they outlive the shutdown timeout
+	procMu        sync.Mutex
+	taskErrors    []TaskError // Failed tasks, reported at the end of the run
+	errorsMu      sync.Mutex
 	// Performance tracking
 	startTime       time.Time
 	endTime         time.Time
@@ -44,14 +233,83 @@ type Runner struct {
 }
 
 type Task struct {
-	ID         int
-	InputData  string
-	WindowName string
+	// InputData is the line range ("lines_start_end") in multiple mode or the target of a
+	// split_wordlist task. Single-mode tasks leave it empty: their line is inputLines[ID].
+	ID        int
+	InputData string
+	Lines     []int // Explicit input line indexes (round-robin distribution); nil means InputData holds a line range
+	// WordlistChunk is the wordlist line range ("lines_start_end") for split_wordlist tools
+	WordlistChunk string
+	WindowName    string
+	// StderrTail holds the last --stderr-tail lines the task's most recent run wrote to stderr
+	StderrTail []string
 	Status     TaskStatus
 	StartTime  time.Time
 	EndTime    time.Time
+	collected  *taskOutput // Collects the task's output for collapse_output or --format task-json; nil otherwise
 }
 
+// Open files bulker needs besides its workers (input, output, logs, reports) and for each worker
+// (the task's stdout and stderr pipes, both ends while it starts)
+const (
+	reservedOpenFiles  = 32
+	openFilesPerWorker = 4
+)
+
+// workersForOpenFiles is how many workers fit in a soft limit of limit open files, at least one
+func workersForOpenFiles(limit uint64) int {
+	if limit <= reservedOpenFiles+openFilesPerWorker {
+		return 1
+	}
+	fit := (limit - reservedOpenFiles) / openFilesPerWorker
+	if fit > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(fit)
+}
+
+// dynamicUnitsPerWorker is how many small units each worker's share of the input is broken into
+// when the dynamic scheduler is used, so that idle workers can pick up remaining work.
+const dynamicUnitsPerWorker = 8
+
+// Output writer tuning: default queued blocks before tasks wait (--output-buffer), buffer size,
+// and how often buffered output is flushed and synced by default (--flush-interval)
+const (
+	defaultOutputQueueSize = 4096
+	outputBufferSize       = 256 * 1024
+	defaultFlushInterval   = time.Second
+)
+
+// defaultMaxLineSize is the longest line read from input, wordlists and tool output unless
+// --max-line-size says otherwise; bufio.Scanner's own 64KB limit is easily hit by long URLs
+const defaultMaxLineSize = 16 * 1024 * 1024
+
+// newLineScanner returns a line scanner that accepts lines up to maxLineSize bytes, or
+// defaultMaxLineSize when it is 0. The buffer starts small and only grows for long lines.
+func newLineScanner(reader io.Reader, maxLineSize int) *bufio.Scanner {
+	if maxLineSize <= 0 {
+		maxLineSize = defaultMaxLineSize
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return scanner
+}
+
+// lineScanner returns a line scanner with the run's --max-line-size
+func (r *Runner) lineScanner(reader io.Reader) *bufio.Scanner {
+	return newLineScanner(reader, r.maxLineSize())
+}
+
+func (r *Runner) maxLineSize() int {
+	if r.config.MaxLineSize > 0 {
+		return r.config.MaxLineSize
+	}
+	return defaultMaxLineSize
+}
+
+// minUsefulChunkLines is the chunk size below which multiple mode warns about process-spawn overhead
+const minUsefulChunkLines = 10
+
 type TaskStatus int
 
 const (
@@ -59,12 +317,36 @@ const (
 	TaskRunning
 	TaskCompleted
 	TaskFailed
+	TaskTimedOut
 )
 
+// newRunID returns an ID unique to this run, "<timestamp>-<pid>-<random>" (e.g.
+// 20240501T142233-4121-9f3a1c), so leftover temp files can be traced back to the run that made them
+func newRunID(now time.Time) string {
+	random := make([]byte, 3)
+	if _, err := rand.Read(random); err != nil {
+		// Timestamp and PID alone are still unique unless two runs start in the same second
+		return fmt.Sprintf("%s-%d", now.Format("20060102T150405"), os.Getpid())
+	}
+	return fmt.Sprintf("%s-%d-%s", now.Format("20060102T150405"), os.Getpid(), hex.EncodeToString(random))
+}
+
+// tempFileName names a task's temp file (chunk, wordlist chunk, temp output) in the working
+// directory, namespaced by the run ID so concurrent runs never share one
+func (r *Runner) tempFileName(kind string, n int) string {
+	return fmt.Sprintf("%s_%s_%d.txt", kind, r.runID, n)
+}
+
 func NewRunner(config RunnerConfig) (*Runner, error) {
-	configManager, err := NewConfigManager(config.ConfigFile)
-	if err != nil {
-		return nil, fmt.Errorf("could not load config file: %v", err)
+	var configManager *ConfigManager
+	var err error
+	if config.RawTool != nil {
+		configManager = NewRawConfigManager(*config.RawTool)
+	} else {
+		configManager, err = NewConfigManager(config.ConfigFile, config.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load config file: %v", err)
+		}
 	}
 
 	var toolConfig ToolConfig
@@ -75,46 +357,407 @@ func NewRunner(config RunnerConfig) (*Runner, error) {
 		return nil, fmt.Errorf("tool '%s' not found in config file '%s'", config.Command, config.ConfigFile)
 	}
 
+	if config.Mode != "" && config.Mode != toolConfig.Mode {
+		LogInfo("Running %s in %s mode instead of its configured %s mode", config.Command, config.Mode, toolConfig.Mode)
+		toolConfig.Mode = config.Mode
+	}
+
+	if toolConfig.MaxWorkers > 0 && config.Workers > toolConfig.MaxWorkers {
+		LogWarn("Tool '%s' is limited to %d workers; reducing from %d", config.Command, toolConfig.MaxWorkers, config.Workers)
+		config.Workers = toolConfig.MaxWorkers
+	}
+	// A resize (SIGUSR1) may raise the workers up to maxWorkers, so the open file limit caps it too
+	maxWorkers := toolConfig.MaxWorkers
+	if limit, ok := openFileLimit(); ok {
+		fit := workersForOpenFiles(limit)
+		if config.Workers > fit {
+			LogWarn("The open file limit (ulimit -n %d) leaves room for %d workers; reducing from %d", limit, fit, config.Workers)
+			config.Workers = fit
+		}
+		if maxWorkers == 0 || maxWorkers > fit {
+			maxWorkers = fit
+		}
+	}
+
+	if toolConfig.RetryIfEmpty && toolConfig.Retries < 1 {
+		LogWarn("Tool '%s' sets retry_if_empty but retries is 0; empty output will not be retried", config.Command)
+	}
+	if len(toolConfig.RetryExitCodes) > 0 && toolConfig.Retries < 1 {
+		LogWarn("Tool '%s' sets retry_exit_codes but retries is 0; failed tasks will not be retried", config.Command)
+	}
+	for _, code := range toolConfig.RetryExitCodes {
+		if code <= 0 {
+			return nil, fmt.Errorf("invalid retry_exit_codes entry %d for tool '%s' (exit code 0 is success)", code, config.Command)
+		}
+	}
+
+	if toolConfig.CollapseOutput && config.OutputFormat == taskJSONFormat {
+		return nil, fmt.Errorf("tool '%s' uses collapse_output, which cannot be combined with --format %s", config.Command, taskJSONFormat)
+	}
+	if toolConfig.Persistent && config.OutputFormat == taskJSONFormat {
+		return nil, fmt.Errorf("persistent tool '%s' shares one process between tasks, so its output cannot be written with --format %s", config.Command, taskJSONFormat)
+	}
+
+	if toolConfig.CollapseOutput {
+		if toolConfig.Mode != "single" || toolConfig.Persistent {
+			return nil, fmt.Errorf("tool '%s' uses collapse_output, which needs mode = \"single\" without persistent", config.Command)
+		}
+		if toolConfig.Parser != "" {
+			return nil, fmt.Errorf("tool '%s' cannot combine collapse_output with a parser", config.Command)
+		}
+	}
+
+	if err := validateInputMode(config.Command, toolConfig); err != nil {
+		return nil, err
+	}
+	if err := checkPlaceholders(config.Command, toolConfig.Command); err != nil {
+		return nil, err
+	}
+	if strings.Contains(toolConfig.Command, "{input_file}") && (toolConfig.Mode != "multiple" || toolConfig.SplitWordlist || toolConfig.Persistent) {
+		return nil, fmt.Errorf("tool '%s' uses {input_file}, the chunk file of multiple mode; in this mode the input is a line, use {input}", config.Command)
+	}
+	if usesHostPort(toolConfig.Command) {
+		if toolConfig.Mode != "single" || toolConfig.Persistent {
+			return nil, fmt.Errorf("tool '%s' uses {host} or {port}, which are taken from each input line and need mode = \"single\" without persistent", config.Command)
+		}
+		if toolConfig.DefaultPort != "" {
+			if _, _, err := splitHostPort("localhost", toolConfig.DefaultPort); err != nil {
+				return nil, fmt.Errorf("invalid default_port '%s' for tool '%s'", toolConfig.DefaultPort, config.Command)
+			}
+		}
+	}
+	if config.Remote != "" {
+		if err := validateRemote(config.Command, toolConfig); err != nil {
+			return nil, err
+		}
+	}
+	if toolConfig.Container != "" {
+		if err := validateContainer(config.Command, toolConfig, config.Remote); err != nil {
+			return nil, err
+		}
+	}
+
+	if toolConfig.Persistent {
+		if toolConfig.InputMode != "" && toolConfig.InputMode != InputModePlaceholder && toolConfig.InputMode != InputModeStdin {
+			return nil, fmt.Errorf("persistent tool '%s' always reads its input from stdin, so input_mode must be stdin", config.Command)
+		}
+		if strings.Contains(toolConfig.Command, "{input}") || strings.Contains(toolConfig.Command, "{output}") {
+			return nil, fmt.Errorf("persistent tool '%s' reads targets from stdin and writes results to stdout, so its command cannot use {input} or {output}", config.Command)
+		}
+		if toolConfig.SplitWordlist {
+			return nil, fmt.Errorf("tool '%s' cannot combine persistent with split_wordlist", config.Command)
+		}
+	}
+
+	if toolConfig.CombineOutput && toolConfig.CaptureStderr {
+		return nil, fmt.Errorf("tool '%s' cannot combine combine_output with capture_stderr", config.Command)
+	}
+
+	// Remote hosts and containers run sh, which always supports no_glob
+	localShell := !toolConfig.DirectExec && config.Remote == "" && toolConfig.Container == ""
+	if (toolConfig.NoGlob || config.NoGlob) && localShell {
+		if _, ok := noGlobCommand(defaultShell(config.Shell)); !ok {
+			return nil, fmt.Errorf("no_glob is not supported with shell '%s'; use bash, sh or zsh", defaultShell(config.Shell))
+		}
+	}
+
+	var outputParser OutputParser
+	if toolConfig.Parser != "" {
+		outputParser, err = GetOutputParser(toolConfig.Parser)
+		if err != nil {
+			return nil, err
+		}
+		if toolConfig.ParserFormat != "" && toolConfig.ParserFormat != "json" && toolConfig.ParserFormat != "tsv" {
+			return nil, fmt.Errorf("invalid parser_format '%s' for tool '%s' (use json or tsv)", toolConfig.ParserFormat, config.Command)
+		}
+	}
+
+	commentPrefix := toolConfig.CommentPrefix
+	if commentPrefix == "" {
+		commentPrefix = "#"
+	}
+	if config.NoComments {
+		commentPrefix = ""
+	}
+
+	var inputPattern *regexp.Regexp
+	if toolConfig.InputPattern != "" {
+		inputPattern, err = regexp.Compile(toolConfig.InputPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input_pattern for tool '%s': %v", config.Command, err)
+		}
+	}
+
+	var since *sinceFilter
+	if config.Since != "" {
+		since, err = newSinceFilter(config.Since, config.TimeRegex, config.TimeFormat, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Runner{
 		config:        config,
 		signalHandler: NewSignalHandler(),
 		configManager: configManager,
 		toolConfig:    toolConfig,
+		maxWorkers:    maxWorkers,
+		outputParser:  outputParser,
 		outputPath:    config.OutputFile,
+		commentPrefix: commentPrefix,
+		inputPattern:  inputPattern,
+		sinceFilter:   since,
+		processes:     make(map[*exec.Cmd]struct{}),
 		cancelChan:    make(chan struct{}),
+		runID:         newRunID(time.Now()),
 	}, nil
 }
 
 func (r *Runner) readInputFile() error {
-	var scanner *bufio.Scanner
+	r.inputLines = make([]string, 0)
+	if r.config.Sample > 0 {
+		// Deferred first so it runs last, once the other filters have logged what they kept
+		defer r.sampleInput()
+	}
+	if r.config.ShardCount > 1 {
+		defer func() {
+			LogInfo("Shard %d/%d: kept %d lines, skipped %d belonging to other shards", r.config.ShardIndex, r.config.ShardCount, len(r.inputLines), r.shardSkipped)
+		}()
+	}
+	if r.sinceFilter != nil {
+		defer func() {
+			LogInfo("Skipped %d input lines from before %s (%d without a timestamp)", r.oldCount, r.sinceFilter.cutoff.Format(time.RFC3339), r.undatedCount)
+		}()
+	}
+	defer func() {
+		if r.commentCount > 0 {
+			LogInfo("Skipped %d comment lines starting with %q", r.commentCount, r.commentPrefix)
+		}
+		if r.skippedDone > 0 {
+			LogInfo("Skipped %d input lines that were already processed", r.skippedDone)
+		}
+		if r.invalidCount > 0 {
+			LogWarn("Skipped %d input lines not matching input_pattern %s", r.invalidCount, r.inputPattern)
+		}
+		if r.binaryCount > 0 {
+			LogWarn("Skipped %d input lines with binary data or invalid UTF-8", r.binaryCount)
+		}
+		if r.nonUTF8Count > 0 {
+			LogWarn("%d input lines are not valid UTF-8 and are passed to the tool as is (--skip-binary skips them)", r.nonUTF8Count)
+		}
+	}()
+	if r.config.DedupInput {
+		r.seenLines = make(map[string]struct{})
+		defer func() {
+			LogInfo("Removed %d duplicate input lines", r.dupCount)
+		}()
+	}
+
+	if r.config.InputDir != "" {
+		return r.readInputDir()
+	}
+
+	if len(r.config.Targets) > 0 {
+		if err := r.appendInputLines(strings.NewReader(strings.Join(r.config.Targets, "\n")), ""); err != nil {
+			return err
+		}
+		LogInfo("Using %d targets from --targets", len(r.inputLines))
+		return nil
+	}
+
+	var input io.Reader
 
 	// If no input file is specified, read from stdin
 	if r.config.InputFile == "" {
 		LogInfo("Reading input from stdin")
-		scanner = bufio.NewScanner(os.Stdin)
+		input = os.Stdin
 	} else {
 		file, err := os.Open(r.config.InputFile)
 		if err != nil {
 			return fmt.Errorf("failed to open input file: %w", err)
 		}
 		defer file.Close()
-		scanner = bufio.NewScanner(file)
+		input = file
 	}
 
-	r.inputLines = make([]string, 0)
+	if err := r.appendInputLines(input, ""); err != nil {
+		return err
+	}
 
-	for scanner.Scan() {
+	LogInfo("Read %d lines of input", len(r.inputLines))
+	return nil
+}
+
+// inputDescription names where the input was read from, for messages
+func (r *Runner) inputDescription() string {
+	switch {
+	case r.config.InputDir != "":
+		pattern := r.config.InputPattern
+		if pattern == "" {
+			pattern = "*.txt"
+		}
+		return fmt.Sprintf("input directory %s (%s)", r.config.InputDir, pattern)
+	case len(r.config.Targets) > 0:
+		return "--targets"
+	case r.config.InputFile == "":
+		return "stdin"
+	}
+	return fmt.Sprintf("input file %s", r.config.InputFile)
+}
+
+// readInputDir reads every file in InputDir matching InputPattern, in sorted filename order,
+// as one concatenated input stream.
+func (r *Runner) readInputDir() error {
+	pattern := r.config.InputPattern
+	if pattern == "" {
+		pattern = "*.txt"
+	}
+
+	matches, err := filepath.Glob(filepath.Join(r.config.InputDir, pattern))
+	if err != nil {
+		return fmt.Errorf("invalid input pattern %s: %w", pattern, err)
+	}
+	sort.Strings(matches)
+
+	fileCount := 0
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open input file %s: %w", path, err)
+		}
+		before := len(r.inputLines)
+		tag := ""
+		if r.config.TagSource {
+			tag = filepath.Base(path)
+		}
+		err = r.appendInputLines(file, tag)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		LogInfo("Read %d lines from %s", len(r.inputLines)-before, path)
+		fileCount++
+	}
+
+	if fileCount == 0 {
+		LogWarn("No files matching %s found in %s", pattern, r.config.InputDir)
+	}
+
+	LogInfo("Read %d lines of input from %d files", len(r.inputLines), fileCount)
+	return nil
+}
+
+// appendInputLines adds the non-empty lines read from reader (whole records with --input-format
+// csv) to the input, prefixed with "<source>:" when source is not empty. Comment lines are
+// dropped before the tool's preprocess command sees them; sharding and DedupInput apply to the
+// preprocessed lines, so a line already seen (before tagging) is skipped.
+func (r *Runner) appendInputLines(reader io.Reader, source string) error {
+	scanner, extract, err := newInputScanner(reader, r.maxLineSize(), r.config.InputFormat, r.config.JSONField, r.config.CSVColumn)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	skipped := 0
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
 		line := scanner.Text()
-		if line != "" { // Only add non-empty lines
-			r.inputLines = append(r.inputLines, line)
+		if line == "" {
+			continue
+		}
+		r.readCount++
+		if nul := strings.IndexByte(line, 0) >= 0; nul || !utf8.ValidString(line) {
+			if r.config.SkipBinary {
+				r.binaryCount++
+				continue
+			}
+			if nul {
+				return fmt.Errorf("input line %d contains a NUL byte, so the input looks binary (pass --skip-binary to skip such lines)", lineNumber)
+			}
+			r.nonUTF8Count++
 		}
+		if r.commentPrefix != "" && strings.HasPrefix(strings.TrimSpace(line), r.commentPrefix) {
+			r.commentCount++
+			continue
+		}
+		if r.sinceFilter != nil {
+			keep, ok := r.sinceFilter.keep(line)
+			if !ok {
+				r.undatedCount++
+			}
+			if !keep {
+				r.oldCount++
+				continue
+			}
+		}
+		if extract != nil {
+			value, ok, err := extract(line)
+			if err != nil {
+				return fmt.Errorf("input line %d: %w", lineNumber, err)
+			}
+			if !ok {
+				skipped++
+				continue
+			}
+			line = value
+		}
+		lines = append(lines, line)
+	}
+	if skipped > 0 && r.config.InputFormat == "json" {
+		LogWarn("Skipped %d JSON records without a value at %s", skipped, r.config.JSONField)
 	}
 
 	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("error reading input: a line is longer than %d bytes (raise it with --max-line-size)", r.maxLineSize())
+		}
 		return fmt.Errorf("error reading input: %w", err)
 	}
 
-	LogInfo("Read %d lines of input", len(r.inputLines))
+	if r.toolConfig.Preprocess != "" && len(lines) > 0 {
+		before := len(lines)
+		lines, err = r.preprocessLines(lines)
+		if err != nil {
+			return err
+		}
+		LogInfo("Preprocessed %d input lines into %d", before, len(lines))
+	}
+
+	for _, line := range lines {
+		if line != "" { // Only add non-empty lines
+			if r.inputPattern != nil && !r.inputPattern.MatchString(strings.TrimSpace(line)) {
+				if r.config.Strict {
+					return fmt.Errorf("input line %q does not match input_pattern %s", line, r.inputPattern)
+				}
+				r.invalidCount++
+				continue
+			}
+			if r.config.ShardCount > 1 && !inShard(line, r.config.ShardIndex, r.config.ShardCount) {
+				r.shardSkipped++
+				continue
+			}
+			if _, done := r.config.SkipLines[line]; done {
+				r.skippedDone++
+				continue
+			}
+			if r.seenLines != nil {
+				if _, seen := r.seenLines[line]; seen {
+					r.dupCount++
+					continue
+				}
+				r.seenLines[line] = struct{}{}
+			}
+			if source != "" {
+				line = source + ":" + line
+			}
+			r.inputLines = append(r.inputLines, line)
+		}
+	}
 	return nil
 }
 
@@ -138,49 +781,74 @@ func (r *Runner) parseLineRange(rangeStr string) (int, int, error) {
 	return startLine, endLine, nil
 }
 
-func (r *Runner) Run() error {
+func (r *Runner) Run() (err error) {
 	// Start performance tracking
 	r.startTime = time.Now()
 	runtime.ReadMemStats(&r.initialMemStats)
 
+	LogInfo("Run ID: %s", r.runID)
+
+	if r.config.SafeOutput {
+		// Deferred first so it runs after the output is closed
+		defer func() { r.restoreBackup(err) }()
+	}
+
 	// Setup signal handling
 	r.signalHandler.Setup(r.handleInterrupt)
 	defer r.signalHandler.Stop()
 
-	// Backup existing output file if it exists
-	if err := r.backupOutputFile(); err != nil {
-		return fmt.Errorf("failed to backup output file: %w", err)
-	}
+	// Stop launching and kill running tasks if whoever reads our stdout goes away
+	SetBrokenPipeHandler(r.cancelTasks)
+	defer SetBrokenPipeHandler(nil)
+
+	// With CountOnly results are only counted, so there is no output file to set up
+	if !r.config.CountOnly {
+		// Backup existing output file if it exists
+		if !r.config.AppendOutput && !r.toStdout() {
+			if err := r.backupOutputFile(); err != nil {
+				return fmt.Errorf("failed to backup output file: %w", err)
+			}
+		}
 
-	// Create output directory if needed
-	outputDir := filepath.Dir(r.config.OutputFile)
-	if outputDir != "." && outputDir != "" {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			return fmt.Errorf("failed to create output directory: %w", err)
+		// Create output directory if needed
+		outputDir := filepath.Dir(r.config.OutputFile)
+		if outputDir != "." && outputDir != "" {
+			if err := r.createOutputDir(outputDir); err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
+		}
+
+		// Create output file
+		firstPath := r.outputPath
+		if r.config.OutputSplit > 0 {
+			r.outputPart = 1
+			firstPath = splitPartPath(r.outputPath, 1)
+		}
+		if err := r.openOutputFile(firstPath); err != nil {
+			return err
 		}
+		r.startOutputWriter()
+		defer r.closeOutputFile()
 	}
 
-	// Create output file
-	var err error
-	r.outputFile, err = os.Create(r.outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+	// Read input file directly into memory
+	if err := r.readInputFile(); err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
 	}
-	// Write header if defined in config
-	if r.toolConfig.Header != "" {
-		r.outputFile.WriteString(r.toolConfig.Header + "\n")
+	if r.readCount == 0 && !r.config.AllowEmptyInput {
+		return fmt.Errorf("%s is empty, there are no lines to process (pass --allow-empty-input to accept this)", r.inputDescription())
 	}
-	defer func() {
-		if r.outputFile != nil {
-			r.outputFile.Sync()
-			r.outputFile.Close()
+
+	if r.config.PriorityFile != "" {
+		if err := r.prioritizeInput(); err != nil {
+			return err
 		}
-	}()
+	}
 
-	// Read input file directly into memory
-	err = r.readInputFile()
-	if err != nil {
-		return fmt.Errorf("failed to read input file: %w", err)
+	if r.toolConfig.SplitWordlist {
+		if err := r.readWordlist(); err != nil {
+			return err
+		}
 	}
 
 	// Create tasks based on line ranges
@@ -191,16 +859,21 @@ func (r *Runner) Run() error {
 		return fmt.Errorf("failed to setup tool strategy: %w", err)
 	}
 
-	// Run tasks
-	if err := r.runTasks(); err != nil {
-		return fmt.Errorf("failed to run tasks: %w", err)
-	}
+	// Run tasks in the background so monitor can report progress while they execute
+	tasksDone := make(chan error, 1)
+	go func() {
+		tasksDone <- r.runTasks()
+	}()
 
 	// Monitor and wait for completion
 	if err := r.monitor(); err != nil {
 		return fmt.Errorf("monitoring failed: %w", err)
 	}
 
+	if err := <-tasksDone; err != nil {
+		return fmt.Errorf("failed to run tasks: %w", err)
+	}
+
 	// End performance tracking
 	r.endTime = time.Now()
 	runtime.ReadMemStats(&r.finalMemStats)
@@ -208,82 +881,288 @@ func (r *Runner) Run() error {
 	// Processing completed
 	LogInfo("Processing completed")
 
-	LogSuccess("All tasks completed successfully! Output written to: %s", r.outputPath)
+	if r.config.OutputSplit > 0 {
+		// The writer owns the part count; once it has finished, the count is final
+		r.closeOutputFile()
+	}
+
+	failed, unfinished := r.FailedCount(), r.unfinishedCount()
+	switch {
+	case r.config.CountOnly && (failed > 0 || unfinished > 0):
+		LogWarn("Run finished with %d failed and %d unfinished tasks. Results: %d lines (count may be incomplete)", failed, unfinished, r.resultCount.Load())
+	case r.config.CountOnly:
+		LogSuccess("All tasks completed successfully! Results: %d lines", r.resultCount.Load())
+	case failed > 0 || unfinished > 0:
+		LogWarn("Run finished with %d failed and %d unfinished tasks. Output written to: %s", failed, unfinished, r.outputLocation())
+	default:
+		LogSuccess("All tasks completed successfully! Output written to: %s", r.outputLocation())
+	}
+
+	r.reportTaskErrors()
 
 	// Display performance metrics
 	r.displayPerformanceMetrics()
 
+	if r.config.Checksum || r.config.ChecksumFile {
+		// The output must be fully flushed (and the gzip trailer written) before it is hashed
+		r.closeOutputFile()
+		if err := r.writeChecksum(); err != nil {
+			LogWarn("Failed to compute output checksum: %v", err)
+		}
+	}
+
+	if r.config.MetricsFile != "" {
+		r.writeMetrics()
+	}
+	if r.config.NotifyURL != "" {
+		r.notify()
+	}
+
+	if r.config.RequireOutput && !r.producedOutput() {
+		return fmt.Errorf("no output was produced (--require-output)")
+	}
+
 	return nil
 }
 
-func (r *Runner) backupOutputFile() error {
-	if _, err := os.Stat(r.outputPath); os.IsNotExist(err) {
-		// File doesn't exist, no need to backup
-		return nil
-	} else if err != nil {
-		// Other error
+// writeChecksum logs the SHA-256 of the finished output file and, with ChecksumFile,
+// writes it to a "<output>.sha256" sidecar in the format read by `sha256sum -c`.
+func (r *Runner) writeChecksum() error {
+	file, err := os.Open(r.outputPath)
+	if err != nil {
 		return err
 	}
+	defer file.Close()
 
-	// File exists, create backup name
-	timestamp := time.Now().Format("20060102_150405")
-	ext := filepath.Ext(r.outputPath)
-	base := strings.TrimSuffix(r.outputPath, ext)
-	backupPath := fmt.Sprintf("%s_%s%s", base, timestamp, ext)
-
-	LogInfo("Output file %s exists. Backing up to %s", r.outputPath, backupPath)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	LogPerf("Output SHA-256: %s", sum)
 
-	return os.Rename(r.outputPath, backupPath)
+	if !r.config.ChecksumFile {
+		return nil
+	}
+	sidecar := r.outputPath + ".sha256"
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(r.outputPath))
+	out, err := createFileMode(sidecar, r.config.OutputMode)
+	if err != nil {
+		return err
+	}
+	if _, err := out.WriteString(line); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	LogInfo("Checksum written to %s", sidecar)
+	return nil
 }
 
-func (r *Runner) createTasks() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// summary collects the run's outcome, as sent to NotifyURL and written to MetricsFile
+func (r *Runner) summary() RunNotification {
+	failed, unfinished := r.FailedCount(), r.unfinishedCount()
 
-	totalLines := len(r.inputLines)
-	if totalLines == 0 {
-		LogWarn("No lines to process")
-		return
+	status := "completed"
+	select {
+	case <-r.cancelChan:
+		status = "interrupted"
+	default:
+		if failed > 0 || unfinished > 0 {
+			status = "completed_with_failures"
+		}
 	}
 
-	r.tasks = make([]Task, 0)
-
-	switch r.toolConfig.Mode {
+	r.mu.RLock()
+	total := len(r.tasks)
+	inputLines := len(r.inputLines)
+	r.mu.RUnlock()
+	linesPerSecond, tasksPerSecond := r.throughput()
+
+	return RunNotification{
+		RunID:          r.runID,
+		Tool:           r.config.Command,
+		Output:         r.outputPath,
+		Status:         status,
+		Total:          total,
+		Completed:      total - failed - unfinished,
+		Failed:         failed,
+		Unfinished:     unfinished,
+		InputLines:     inputLines,
+		Duration:       r.endTime.Sub(r.startTime).Seconds(),
+		LinesPerSecond: linesPerSecond,
+		TasksPerSecond: tasksPerSecond,
+		BytesWritten:   r.bytesWrittenTotal(),
+	}
+}
+
+// notify sends the run summary to NotifyURL, only warning if the webhook fails
+func (r *Runner) notify() {
+	if err := sendNotification(r.config.NotifyURL, r.summary()); err != nil {
+		LogWarn("Failed to send completion notification: %v", err)
+		return
+	}
+	LogInfo("Completion notification sent to %s", r.config.NotifyURL)
+}
+
+// writeMetrics writes the run summary to MetricsFile, only warning if it cannot be written
+func (r *Runner) writeMetrics() {
+	if err := writeMetricsFile(r.config.MetricsFile, r.summary(), r.config.OutputMode); err != nil {
+		LogWarn("Failed to write metrics file: %v", err)
+		return
+	}
+	LogInfo("Metrics written to %s", r.config.MetricsFile)
+}
+
+// backupOutputFile moves an existing output file, and with OutputSplit every existing part, to
+// timestamped backups, so the run neither truncates earlier results nor leaves stale parts next
+// to its own
+func (r *Runner) backupOutputFile() error {
+	timestamp := time.Now().Format("20060102_150405")
+	backupPath, err := backupFile(r.outputPath, timestamp)
+	if err != nil {
+		return err
+	}
+	r.backupPath = backupPath
+
+	if r.config.OutputSplit > 0 {
+		for part := 1; ; part++ {
+			backupPath, err := backupFile(splitPartPath(r.outputPath, part), timestamp)
+			if err != nil {
+				return err
+			}
+			if backupPath == "" {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// backupFile renames path to a name with timestamp before its extension and returns that name,
+// or "" if path does not exist
+func backupFile(path, timestamp string) (string, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// File doesn't exist, no need to backup
+		return "", nil
+	} else if err != nil {
+		// Other error
+		return "", err
+	}
+
+	// File exists, create backup name
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	backupPath := fmt.Sprintf("%s_%s%s", base, timestamp, ext)
+
+	LogInfo("Output file %s exists. Backing up to %s", path, backupPath)
+
+	if err := os.Rename(path, backupPath); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// restoreBackup puts the output file backed up at the start of the run back in place of the new
+// one when the run failed: Run returned runErr, or tasks ran and none of them succeeded. Used
+// with SafeOutput, so a broken config or dead target does not bury good earlier results.
+func (r *Runner) restoreBackup(runErr error) {
+	if r.backupPath == "" {
+		return
+	}
+	if runErr == nil {
+		r.mu.RLock()
+		total, succeeded := len(r.tasks), 0
+		for _, task := range r.tasks {
+			if task.Status == TaskCompleted {
+				succeeded++
+			}
+		}
+		r.mu.RUnlock()
+		if total == 0 || succeeded > 0 {
+			return
+		}
+	}
+
+	r.closeOutputFile()
+	// The failed run may have rotated into more files; none of them belongs next to the restored output
+	for part := r.outputPart; part >= 0; part-- {
+		path := r.outputPath
+		if part > 0 {
+			path = rotatedPartPath(r.outputPath, part)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			LogWarn("Failed to remove the output of the failed run, previous output kept in %s: %v", r.backupPath, err)
+			return
+		}
+	}
+	if err := os.Rename(r.backupPath, r.outputPath); err != nil {
+		LogWarn("Failed to restore the previous output from %s: %v", r.backupPath, err)
+		return
+	}
+	LogWarn("No task succeeded; restored the previous output to %s", r.outputPath)
+}
+
+func (r *Runner) createTasks() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totalLines := len(r.inputLines)
+	if totalLines == 0 {
+		if r.readCount > 0 {
+			LogWarn("No lines to process: all %d input lines were filtered out", r.readCount)
+		} else {
+			LogWarn("No lines to process")
+		}
+		return
+	}
+
+	r.tasks = make([]Task, 0)
+	defer r.nameTasks()
+
+	if r.toolConfig.SplitWordlist {
+		r.createWordlistTasks()
+		return
+	}
+
+	switch r.toolConfig.Mode {
 	case "multiple":
-		// Chia input thành các chunks, mỗi chunk là một task
-		chunkSize := totalLines / r.config.Workers
-		if totalLines%r.config.Workers != 0 {
-			chunkSize++
+		if r.config.SplitBytes > 0 {
+			r.createByteSizedTasks()
+			return
+		}
+		if r.toolConfig.Distribution == "round_robin" {
+			r.createRoundRobinTasks()
+			return
 		}
-		if chunkSize < 1 {
-			chunkSize = 1
+		// Chia input thành các chunks, mỗi chunk là một task
+		chunkCount := r.config.Workers
+		if r.config.Scheduler == "dynamic" {
+			// Many small units instead of one per worker, pulled by workers as they become free
+			chunkCount = r.config.Workers * dynamicUnitsPerWorker
 		}
+		ranges := splitLinesEvenly(totalLines, chunkCount)
+		chunkSize := ranges[0][1] - ranges[0][0] + 1
 		LogInfo("Total lines: %d, Workers: %d, Chunk size: %d", totalLines, r.config.Workers, chunkSize)
-		taskID := 0
-		for startLine := 0; startLine < totalLines; startLine += chunkSize {
-			endLine := startLine + chunkSize
-			if endLine > totalLines {
-				endLine = totalLines
-			}
-			LogInfo("Creating task %d: lines %d-%d", taskID, startLine, endLine-1)
+		if r.config.Scheduler != "dynamic" {
+			r.warnSmallChunks(totalLines, chunkSize)
+		}
+		for taskID, lineRange := range ranges {
+			LogInfo("Creating task %d: lines %d-%d", taskID, lineRange[0]+1, lineRange[1]+1)
 			r.tasks = append(r.tasks, Task{
-				ID:         taskID,
-				InputData:  fmt.Sprintf("lines_%d_%d", startLine, endLine-1),
-				WindowName: fmt.Sprintf("worker_%d", taskID),
-				Status:     TaskPending,
+				ID:        taskID,
+				InputData: fmt.Sprintf("lines_%d_%d", lineRange[0], lineRange[1]),
+				Status:    TaskPending,
 			})
-			taskID++
 		}
 	case "single":
-		// Mỗi dòng là một task
+		// Mỗi dòng là một task. The line is read from inputLines by task ID, not stored again.
 		LogInfo("Total lines: %d, Mode: single. Creating %d tasks.", totalLines, totalLines)
-		for i, line := range r.inputLines {
-			r.tasks = append(r.tasks, Task{
-				ID:         i,
-				InputData:  line,
-				WindowName: fmt.Sprintf("worker_%d", i),
-				Status:     TaskPending,
-			})
+		r.tasks = make([]Task, totalLines)
+		for i := range r.tasks {
+			r.tasks[i] = Task{ID: i, Status: TaskPending}
 		}
 	default:
 		// Sẽ không xảy ra nếu config hợp lệ
@@ -291,29 +1170,220 @@ func (r *Runner) createTasks() {
 	}
 }
 
+// warnSmallChunks warns when chunks are so small that spawning a process per chunk likely costs
+// more than the parallelism gains, and suggests a worker count that gives reasonable chunks.
+func (r *Runner) warnSmallChunks(totalLines, chunkSize int) {
+	if r.config.Workers > totalLines {
+		LogWarn("%d workers requested for only %d lines; at most %d tasks will run", r.config.Workers, totalLines, totalLines)
+	}
+	if chunkSize >= minUsefulChunkLines || r.config.Workers <= 1 {
+		return
+	}
+
+	suggested := totalLines / minUsefulChunkLines
+	if suggested < 1 {
+		suggested = 1
+	}
+	LogWarn("Chunk size of %d lines is very small, so process startup may dominate run time. Consider fewer threads (e.g. -t %d)", chunkSize, suggested)
+}
+
+// createByteSizedTasks splits the input into chunks bounded by SplitBytes, never splitting a line.
+// Caller must hold r.mu.
+func (r *Runner) createByteSizedTasks() {
+	ranges := splitLinesByBytes(r.inputLines, r.config.SplitBytes)
+	LogInfo("Total lines: %d, Split size: %d bytes, Chunks: %d", len(r.inputLines), r.config.SplitBytes, len(ranges))
+	for taskID, lineRange := range ranges {
+		LogInfo("Creating task %d: lines %d-%d", taskID, lineRange[0]+1, lineRange[1]+1)
+		r.tasks = append(r.tasks, Task{
+			ID:        taskID,
+			InputData: fmt.Sprintf("lines_%d_%d", lineRange[0], lineRange[1]),
+			Status:    TaskPending,
+		})
+	}
+}
+
+// createRoundRobinTasks assigns line i to task i%Workers so that expensive lines clustered
+// together in the input are spread across workers instead of landing in one straggling chunk.
+// Caller must hold r.mu.
+func (r *Runner) createRoundRobinTasks() {
+	totalLines := len(r.inputLines)
+	taskCount := r.config.Workers
+	if taskCount > totalLines {
+		taskCount = totalLines
+	}
+	if taskCount < 1 {
+		taskCount = 1
+	}
+	LogInfo("Total lines: %d, Workers: %d, Distribution: round_robin", totalLines, taskCount)
+
+	lineIndexes := make([][]int, taskCount)
+	for i := 0; i < totalLines; i++ {
+		lineIndexes[i%taskCount] = append(lineIndexes[i%taskCount], i)
+	}
+
+	for taskID, lines := range lineIndexes {
+		LogInfo("Creating task %d: %d lines (every %d line starting at line %d)", taskID, len(lines), taskCount, taskID+1)
+		r.tasks = append(r.tasks, Task{
+			ID:        taskID,
+			InputData: fmt.Sprintf("round_robin_%d_%d", taskID, taskCount),
+			Lines:     lines,
+			Status:    TaskPending,
+		})
+	}
+}
+
+// createWordlistTasks parallelizes over the wordlist instead of the input: the wordlist is split
+// into chunks (by SplitBytes, or one per worker) and every input line is run once per chunk.
+// Caller must hold r.mu.
+func (r *Runner) createWordlistTasks() {
+	var ranges [][2]int
+	if r.config.SplitBytes > 0 {
+		ranges = splitLinesByBytes(r.wordlistLines, r.config.SplitBytes)
+	} else {
+		ranges = splitLinesEvenly(len(r.wordlistLines), r.config.Workers)
+	}
+	LogInfo("Targets: %d, Wordlist lines: %d, Wordlist chunks: %d", len(r.inputLines), len(r.wordlistLines), len(ranges))
+
+	taskID := 0
+	for _, target := range r.inputLines {
+		for _, lineRange := range ranges {
+			LogInfo("Creating task %d: %s with wordlist lines %d-%d", taskID, target, lineRange[0]+1, lineRange[1]+1)
+			r.tasks = append(r.tasks, Task{
+				ID:            taskID,
+				InputData:     target,
+				WordlistChunk: fmt.Sprintf("lines_%d_%d", lineRange[0], lineRange[1]),
+				Status:        TaskPending,
+			})
+			taskID++
+		}
+	}
+}
+
+// prioritizeInput moves input lines listed in PriorityFile to the front of the input, so the
+// tasks holding them are created, and with the dynamic scheduler started, first
+func (r *Runner) prioritizeInput() error {
+	file, err := os.Open(r.config.PriorityFile)
+	if err != nil {
+		return fmt.Errorf("failed to open priority file: %w", err)
+	}
+	defer file.Close()
+
+	priority := make(map[string]struct{})
+	scanner := r.lineScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			priority[line] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading priority file: %w", err)
+	}
+
+	r.inputLines = prioritizeLines(r.inputLines, priority)
+	matched := 0
+	for _, line := range r.inputLines {
+		if _, ok := priority[line]; !ok {
+			break
+		}
+		matched++
+	}
+	LogInfo("Scheduling %d priority input lines first", matched)
+	return nil
+}
+
+// readWordlist loads the wordlist into memory for split_wordlist tools
+func (r *Runner) readWordlist() error {
+	file, err := os.Open(r.config.Wordlist)
+	if err != nil {
+		return fmt.Errorf("failed to open wordlist: %w", err)
+	}
+	defer file.Close()
+
+	r.wordlistLines = make([]string, 0)
+	scanner := r.lineScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			r.wordlistLines = append(r.wordlistLines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading wordlist: %w", err)
+	}
+
+	LogInfo("Read %d lines of wordlist", len(r.wordlistLines))
+	return nil
+}
+
+// writeLineChunk writes lines[startLine..endLine] to path, one per line
+func writeLineChunk(path string, lines []string, startLine, endLine int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for i := startLine; i <= endLine && i < len(lines); i++ {
+		if _, err := file.WriteString(lines[i] + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nameTasks sets each task's WindowName from WindowNameFormat, zero-padding the ID to the width
+// of the largest ID so names sort correctly (worker_0042). Caller must hold r.mu.
+func (r *Runner) nameTasks() {
+	width := len(strconv.Itoa(max(len(r.tasks)-1, 0)))
+	SetTaskIDWidth(width)
+
+	format := r.config.WindowNameFormat
+	if format == "" {
+		format = "worker_{id}"
+	}
+	for i := range r.tasks {
+		r.tasks[i].WindowName = strings.ReplaceAll(format, "{id}", fmt.Sprintf("%0*d", width, r.tasks[i].ID))
+	}
+}
+
 func (r *Runner) setupToolStrategy() error {
 	// Setup tool strategy for processing
 	LogInfo("Setup tool strategy for %s", r.config.Command)
+	if r.toolConfig.Container != "" {
+		return r.prepareContainerImage()
+	}
 	return nil
 }
 
 func (r *Runner) runTasks() error {
-	semaphore := make(chan struct{}, r.config.Workers)
+	limiter := newWorkerLimiter(r.config.Workers, r.maxWorkers)
+	stopThrottle := r.startMemoryThrottle(limiter)
+	defer stopThrottle()
+	stopResize := r.watchResizeSignals(limiter)
+	defer stopResize()
+
+	if r.toolConfig.Persistent {
+		return r.runTasksPersistent()
+	}
+	if r.config.Scheduler == "dynamic" {
+		return r.runTasksDynamic(limiter)
+	}
 
+	// Tasks take their slot here, in order, before their goroutine starts, so only as many
+	// goroutines exist as tasks are running. With a start delay, the first Workers tasks start
+	// one delay apart.
 	var wg sync.WaitGroup
 	for i := range r.tasks {
+		staggered := r.config.WorkerStartDelay > 0 && i < r.config.Workers
+		if (staggered && !r.sleepStartDelay(min(i, 1))) || !limiter.acquire(r.cancelChan) {
+			LogWarn("Scheduler cancelled, %d tasks not started.", len(r.tasks)-i)
+			break
+		}
+
 		wg.Add(1)
 		go func(taskIndex int) {
 			defer wg.Done()
-
-			select {
-			case <-r.cancelChan:
-				LogWarn("Task %d cancelled.", r.tasks[taskIndex].ID)
-				return
-			case semaphore <- struct{}{}:
-				defer func() { <-semaphore }()
-				r.runTask(taskIndex)
-			}
+			defer limiter.release()
+			r.runTask(taskIndex)
 		}(i)
 	}
 
@@ -321,12 +1391,86 @@ func (r *Runner) runTasks() error {
 	return nil
 }
 
+// sleepStartDelay waits n times WorkerStartDelay, so that workers start one delay apart at the
+// beginning of a run. It returns false if the run is cancelled while waiting.
+func (r *Runner) sleepStartDelay(n int) bool {
+	if r.config.WorkerStartDelay <= 0 || n <= 0 {
+		return true
+	}
+	timer := time.NewTimer(time.Duration(n) * r.config.WorkerStartDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.cancelChan:
+		return false
+	}
+}
+
+// runTasksDynamic feeds task indexes through a channel to a pool of workers. A worker takes a
+// slot from limiter before pulling the next unit, so at most the limiter's capacity of units run at
+// once and a slow unit only holds up one worker. The pool starts at max(Workers, max_workers), no
+// larger than the task count, and grows when live resizing raises the limit past it.
+func (r *Runner) runTasksDynamic(limiter *workerLimiter) error {
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	var poolMu sync.Mutex
+	poolSize, poolClosed := 0, false
+	worker := func(startDelay int) {
+		defer wg.Done()
+		if !r.sleepStartDelay(startDelay) {
+			return // Cancelled; the scheduler stops feeding the queue too
+		}
+		for limiter.acquire(r.cancelChan) {
+			taskIndex, ok := <-queue
+			if !ok {
+				limiter.release()
+				return
+			}
+			r.runTask(taskIndex)
+			limiter.release()
+		}
+	}
+	// growPool starts workers until the pool has size of them (never more than there are tasks)
+	growPool := func(size int) {
+		poolMu.Lock()
+		defer poolMu.Unlock()
+		for ; !poolClosed && poolSize < min(size, len(r.tasks)); poolSize++ {
+			wg.Add(1)
+			go worker(min(poolSize, r.config.Workers-1))
+		}
+	}
+	growPool(max(r.config.Workers, r.toolConfig.MaxWorkers))
+	limiter.onGrow(growPool)
+	defer limiter.onGrow(nil)
+
+	stop := func() {
+		close(queue)
+		poolMu.Lock()
+		poolClosed = true
+		poolMu.Unlock()
+		wg.Wait()
+	}
+	for i := range r.tasks {
+		select {
+		case <-r.cancelChan:
+			LogWarn("Scheduler cancelled, %d tasks not started.", len(r.tasks)-i)
+			stop()
+			return nil
+		case queue <- i:
+		}
+	}
+	stop()
+	return nil
+}
+
 func (r *Runner) runTask(taskIndex int) {
 	// Check if cancelled before starting
 	select {
 	case <-r.cancelChan:
 		LogWarn("Task %d cancelled before start.", taskIndex)
-		r.updateTaskStatus(taskIndex, TaskFailed)
+		r.failTask(taskIndex, nil, errors.New("cancelled before start"), nil)
 		return
 	default:
 	}
@@ -335,11 +1479,17 @@ func (r *Runner) runTask(taskIndex int) {
 	task := &r.tasks[taskIndex]
 	task.Status = TaskRunning
 	task.StartTime = time.Now()
+	if r.collectsTaskOutput() {
+		task.collected = &taskOutput{}
+	}
 	r.mu.Unlock()
+	write := r.taskWriter(task)
 
 	var tempOutputFile string
 	var chunkFile string
+	var wordlistChunkFile string
 	var inputData string
+	var inputFirst string // The task's first input line, for {input_first}
 
 	cleanupFunc := func() {
 		if tempOutputFile != "" {
@@ -357,142 +1507,823 @@ func (r *Runner) runTask(taskIndex int) {
 					}
 
 					trimmedContent := strings.Trim(contentToWrite, "\x00")
-					r.writeToOutput(trimmedContent)
+					if r.config.AppendNewline {
+						// Each task block must end with exactly one newline, otherwise the last line of
+						// this task runs together with the first line of the next one
+						trimmedContent = normalizeTrailingNewline(trimmedContent)
+					}
+					write(trimmedContent)
 
 				} else if !os.IsNotExist(err) {
 					LogError("Failed to read temp output file %s: %v", tempOutputFile, err)
 				}
 			}
-			os.Remove(tempOutputFile)
 		}
-		if chunkFile != "" {
-			os.Remove(chunkFile)
+		if task.collected != nil {
+			r.writeTaskOutput(task)
+		}
+		for _, path := range []string{tempOutputFile, chunkFile, wordlistChunkFile} {
+			if path == "" {
+				continue
+			}
+			if r.config.KeepTemp {
+				if _, err := os.Stat(path); err == nil {
+					LogTask(task.ID, "kept temp file %s", path)
+				}
+				continue
+			}
+			os.Remove(path)
+		}
+	}
+	defer cleanupFunc()
+
+	// Check cancellation again before processing
+	select {
+	case <-r.cancelChan:
+		LogWarn("Task %d cancelled during setup.", taskIndex)
+		r.failTask(taskIndex, nil, errors.New("cancelled during setup"), nil)
+		return
+	default:
+	}
+
+	// Tất cả các tool đều được xử lý thông qua config
+
+	tempOutputFile = r.tempFileName("temp_output", task.ID)
+
+	wordlist := r.config.Wordlist
+	if r.toolConfig.Container != "" && wordlist != "" {
+		// The container mounts the wordlist at its absolute path; relative paths resolve in containerWorkDir
+		if abs, err := filepath.Abs(wordlist); err == nil {
+			wordlist = abs
+		}
+	}
+	if task.WordlistChunk != "" {
+		startLine, endLine, err := r.parseLineRange(task.WordlistChunk)
+		if err != nil {
+			LogError("Failed to parse wordlist range for task %d: %v", task.ID, err)
+			r.failTask(taskIndex, nil, fmt.Errorf("failed to parse wordlist range: %w", err), nil)
+			return
+		}
+		wordlistChunkFile = r.tempFileName("wordlist_chunk", taskIndex)
+		if err := writeLineChunk(wordlistChunkFile, r.wordlistLines, startLine, endLine); err != nil {
+			LogError("Failed to write wordlist chunk for task %d: %v", task.ID, err)
+			r.failTask(taskIndex, nil, fmt.Errorf("failed to write wordlist chunk: %w", err), nil)
+			return
+		}
+		wordlist = wordlistChunkFile
+		// The input line is the target itself, as in single mode
+		inputData = task.InputData
+		inputFirst = task.InputData
+	}
+
+	switch {
+	case task.WordlistChunk != "":
+		// Input already set to the target above
+	case r.toolConfig.Mode == "multiple":
+		lineIndexes, err := r.taskLineIndexes(task)
+		if err != nil {
+			LogError("Failed to parse line range for task %d: %v", task.ID, err)
+			r.failTask(taskIndex, nil, fmt.Errorf("failed to parse line range: %w", err), nil)
+			return
+		}
+
+		chunkFile = r.tempFileName("chunk", taskIndex)
+		file, err := os.Create(chunkFile)
+		if err != nil {
+			LogError("Failed to create chunk file for task %d: %v", task.ID, err)
+			r.failTask(taskIndex, nil, fmt.Errorf("failed to create chunk file: %w", err), nil)
+			return
+		}
+		for _, i := range lineIndexes {
+			if _, err := file.WriteString(r.inputLines[i] + "\n"); err != nil {
+				file.Close()
+				LogError("Failed to write to chunk file for task %d: %v", task.ID, err)
+				r.failTask(taskIndex, nil, fmt.Errorf("failed to write chunk file: %w", err), nil)
+				return
+			}
+		}
+		file.Close()
+		inputData = chunkFile
+		if len(lineIndexes) > 0 {
+			inputFirst = r.inputLines[lineIndexes[0]]
+		}
+
+	case r.toolConfig.Mode == "single":
+		inputData = r.taskInput(task)
+		inputFirst = inputData
+
+	default:
+		LogError("Unknown tool mode: %s", r.toolConfig.Mode)
+		r.failTask(taskIndex, nil, fmt.Errorf("unknown tool mode: %s", r.toolConfig.Mode), nil)
+		return
+	}
+
+	cmdParts, err := r.configManager.BuildCommand(r.config.Command, inputData, inputFirst, r.config.CommandArgs, tempOutputFile, wordlist, r.commandShell())
+	if err != nil {
+		LogError("Failed to build command for task %d: %v", task.ID, err)
+		r.failTask(taskIndex, nil, fmt.Errorf("failed to build command: %w", err), nil)
+		return
+	}
+	if r.config.Remote != "" {
+		cmdParts = r.remoteScript(cmdParts, task, r.remoteUploadName(task, chunkFile), tempOutputFile)
+	}
+
+	// Decide whether to capture stdout based on tool configuration
+	ignoreStdout := !r.toolConfig.UseStdout
+	for attempt := 1; ; attempt++ {
+		capturedLines, retryExit := r.runTaskWithCommand(taskIndex, cmdParts, ignoreStdout, attempt)
+		if !retryExit {
+			if !r.shouldRetryEmpty(taskIndex, tempOutputFile, capturedLines, attempt) {
+				break
+			}
+			LogTask(task.ID, "produced no output, retrying (%d/%d)", attempt, r.toolConfig.Retries)
+		}
+
+		os.Remove(tempOutputFile)
+		if task.collected != nil {
+			task.collected.reset()
+		}
+		r.mu.Lock()
+		task.Status = TaskRunning
+		r.mu.Unlock()
+	}
+}
+
+// taskLineIndexes returns the input line indexes of a multiple-mode task
+func (r *Runner) taskLineIndexes(task *Task) ([]int, error) {
+	if task.Lines != nil {
+		return task.Lines, nil
+	}
+
+	startLine, endLine, err := r.parseLineRange(task.InputData)
+	if err != nil {
+		return nil, err
+	}
+	var lineIndexes []int
+	for i := startLine; i <= endLine && i < len(r.inputLines); i++ {
+		lineIndexes = append(lineIndexes, i)
+	}
+	return lineIndexes, nil
+}
+
+// taskInput returns the line of a single-mode task, or the target of a split_wordlist task
+func (r *Runner) taskInput(task *Task) string {
+	if task.WordlistChunk != "" || r.toolConfig.Mode != "single" {
+		return task.InputData
+	}
+	return r.inputLines[task.ID]
+}
+
+// taskInputLines returns the input lines a task was given: its chunk in multiple mode,
+// otherwise its single line (or split_wordlist target)
+func (r *Runner) taskInputLines(task *Task) []string {
+	if r.toolConfig.Mode != "multiple" || task.WordlistChunk != "" {
+		return []string{r.taskInput(task)}
+	}
+
+	lineIndexes, err := r.taskLineIndexes(task)
+	if err != nil {
+		return []string{task.InputData}
+	}
+	lines := make([]string, 0, len(lineIndexes))
+	for _, i := range lineIndexes {
+		lines = append(lines, r.inputLines[i])
+	}
+	return lines
+}
+
+// shouldRetryExit reports whether a task that failed with err should be run again under
+// retry_exit_codes. attempt counts runs so far and shares the tool's retries budget.
+func (r *Runner) shouldRetryExit(err error, attempt int) bool {
+	if len(r.toolConfig.RetryExitCodes) == 0 || attempt > r.toolConfig.Retries {
+		return false
+	}
+	return slices.Contains(r.toolConfig.RetryExitCodes, exitCode(err))
+}
+
+// shouldRetryEmpty reports whether a task that exited successfully without output should be
+// run again under retry_if_empty. attempt counts runs so far and shares the tool's retries budget.
+// capturedLines is the number of stdout (and capture_stderr) lines written straight to the output.
+func (r *Runner) shouldRetryEmpty(taskIndex int, tempOutputFile string, capturedLines int, attempt int) bool {
+	if !r.toolConfig.RetryIfEmpty || attempt > r.toolConfig.Retries {
+		return false
+	}
+
+	r.mu.RLock()
+	status := r.tasks[taskIndex].Status
+	r.mu.RUnlock()
+	if status != TaskCompleted {
+		return false
+	}
+
+	select {
+	case <-r.cancelChan:
+		return false
+	default:
+	}
+
+	if capturedLines > 0 {
+		return false
+	}
+	if r.toolConfig.UseStdout {
+		return true
+	}
+
+	content, err := os.ReadFile(tempOutputFile)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	trimmed := strings.TrimSpace(strings.Trim(string(content), "\x00"))
+	return trimmed == "" || (r.toolConfig.Header != "" && trimmed == r.toolConfig.Header)
+}
+
+// normalizeTrailingNewline strips trailing line breaks and adds back a single newline.
+// Content that is empty or only line breaks becomes empty.
+func normalizeTrailingNewline(content string) string {
+	trimmed := strings.TrimRight(content, "\r\n")
+	if trimmed == "" {
+		return ""
+	}
+	return trimmed + "\n"
+}
+
+// Line endings for RunnerConfig.LineEnding
+const (
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+	LineEndingKeep = "keep"
+)
+
+// applyLineEnding rewrites the line breaks in content to the configured line ending, so tools
+// that print CRLF (common on Windows) do not leave a mix of endings in the output
+func (r *Runner) applyLineEnding(content string) string {
+	switch r.config.LineEnding {
+	case LineEndingLF:
+		if strings.Contains(content, "\r\n") {
+			content = strings.ReplaceAll(content, "\r\n", "\n")
+		}
+	case LineEndingCRLF:
+		content = strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\n", "\r\n")
+	}
+	return content
+}
+
+// writeToOutput formats content (ANSI stripping, parsing) and queues it for the output writer.
+// Formatting happens here, in the caller's goroutine; the writer only writes. Content sent after
+// the output is closed is dropped.
+func (r *Runner) writeToOutput(content string) {
+	if r.config.StripANSI {
+		content = StripANSI(content)
+	}
+
+	if r.outputParser != nil {
+		format := r.toolConfig.ParserFormat
+		if r.config.OutputFormat != "" && r.config.OutputFormat != "text" {
+			format = r.config.OutputFormat
+		}
+		content = normalizeOutput(content, r.outputParser, format)
+	}
+	r.writeRawOutput(content)
+}
+
+// writeRawOutput queues content that is already formatted, such as a task-json record
+func (r *Runner) writeRawOutput(content string) {
+	if content == "" {
+		return
+	}
+
+	if r.config.CountOnly {
+		r.resultCount.Add(int64(countLines(content)))
+		return
+	}
+	content = r.applyLineEnding(content)
+
+	// The read lock only guards against sending on a closed channel; writers never wait on each other.
+	// The queue is bounded, so when the disk can't keep up this send blocks the task until the writer
+	// catches up. closeOutputFile waits for blocked sends, and the writer drains the queue before closing.
+	r.outputMutex.RLock()
+	defer r.outputMutex.RUnlock()
+	if r.outputClosed {
+		return
+	}
+	r.outputChan <- content
+}
+
+// startOutputWriter starts the goroutine that owns the output file: it is the only one to write
+// to it, through a buffer that is flushed and synced every FlushInterval and when the output is closed.
+func (r *Runner) startOutputWriter() {
+	queueSize := r.config.OutputBuffer
+	if queueSize <= 0 {
+		queueSize = defaultOutputQueueSize
+	}
+	flushInterval := r.config.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	r.outputChan = make(chan string, queueSize)
+	r.outputDone = make(chan struct{})
+
+	go func() {
+		defer close(r.outputDone)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case content, ok := <-r.outputChan:
+				if !ok {
+					r.finishOutputFile()
+					return
+				}
+				r.writeOutputContent(content)
+			case <-ticker.C:
+				r.syncOutput()
+			}
+		}
+	}()
+}
+
+// writeOutputContent writes one block of content, applying --output-split. Writer goroutine only.
+func (r *Runner) writeOutputContent(content string) {
+	if r.config.OutputSplit <= 0 {
+		r.writeOutputBlock(content)
+		return
+	}
+	for content != "" && !r.outputFull {
+		if r.outputLines >= r.config.OutputSplit {
+			if err := r.nextSplitPart(); err != nil {
+				r.outputFull = true
+				LogError("Failed to start the next output part, stopping the run: %v", err)
+				r.cancelTasks()
+				return
+			}
+		}
+		piece, rest := takeLines(content, r.config.OutputSplit-r.outputLines)
+		r.writeOutputBlock(piece)
+		content = rest
+	}
+}
+
+// writeOutputBlock writes content to the current output file, applying --max-output-size. Writer goroutine only.
+func (r *Runner) writeOutputBlock(content string) {
+	if r.outputFull {
+		return
+	}
+	// A block larger than the limit still goes into an empty file, otherwise it could never be written
+	if r.config.MaxOutputSize > 0 && r.outputBytes > r.headerBytes && r.outputBytes+int64(len(content)) > r.config.MaxOutputSize {
+		if !r.config.Rotate {
+			r.outputFull = true
+			LogWarn("Output file reached --max-output-size of %d bytes, stopping the run", r.config.MaxOutputSize)
+			r.cancelTasks()
+			return
+		}
+		if err := r.rotateOutputFile(); err != nil {
+			r.outputFull = true
+			LogError("Failed to rotate output file, stopping the run: %v", err)
+			r.cancelTasks()
+			return
+		}
+	}
+
+	// Content already has newlines handled by the cleanup function
+	if _, err := r.outputWriter.WriteString(content); err != nil {
+		r.outputWriteFailed(err)
+	} else {
+		r.outputBytes += int64(len(content))
+		r.bytesWritten.Add(int64(len(content)))
+		r.resultBytes.Add(int64(len(content)))
+		r.outputLines += countLines(content)
+		r.outputDirty = true
+	}
+
+	if r.config.Tail {
+		// writeConsole holds the console lock for the whole block, so it is never split by task logs
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		writeConsole(content)
+	}
+}
+
+// stdoutPath as OutputFile writes the results to stdout, for piping into another command
+const stdoutPath = "-"
+
+// toStdout reports whether results are written to stdout instead of a file
+func (r *Runner) toStdout() bool {
+	return r.outputPath == stdoutPath
+}
+
+// outputWriteFailed reports a failed write to the output. With --output - a closed pipe (e.g.
+// `| head`) means nobody wants more results, so the run is stopped instead of logging every write.
+func (r *Runner) outputWriteFailed(err error) {
+	if r.toStdout() && isBrokenPipe(err) {
+		r.stdoutClosed.Do(func() {
+			LogWarn("Stdout was closed, stopping")
+			r.cancelTasks()
+		})
+		return
+	}
+	LogError("Failed to write to output file: %v", err)
+}
+
+// flushOutput pushes buffered output (and pending compressed data) to the file
+func (r *Runner) flushOutput() error {
+	if r.outputWriter == nil {
+		return nil
+	}
+	if err := r.outputWriter.Flush(); err != nil {
+		return err
+	}
+	if r.outputGzip != nil {
+		return r.outputGzip.Flush()
+	}
+	return nil
+}
+
+// syncOutput flushes output written since the last sync and syncs the file, so that it survives
+// a crash of bulker or of the machine. Idle ticks cost nothing. Writer goroutine only.
+func (r *Runner) syncOutput() {
+	if !r.outputDirty {
+		return
+	}
+	r.outputDirty = false
+	if err := r.flushOutput(); err != nil {
+		r.outputWriteFailed(err)
+		return
+	}
+	if r.outputFile != nil && r.outputFile != os.Stdout {
+		if err := r.outputFile.Sync(); err != nil {
+			LogWarn("Failed to sync output file: %v", err)
+		}
+	}
+}
+
+// createOutputDir creates the output directory, with --dir-mode when it is set
+func (r *Runner) createOutputDir(dir string) error {
+	if r.config.DirMode == 0 {
+		return os.MkdirAll(dir, 0755)
+	}
+	return mkdirAllMode(dir, r.config.DirMode)
+}
+
+// openOutputFile creates path as the current output file and writes the tool's header to it.
+// It is called before the output writer starts, then only by the writer goroutine.
+func (r *Runner) openOutputFile(path string) error {
+	var file *os.File
+	var err error
+	if path == stdoutPath {
+		file = os.Stdout
+	} else if r.config.AppendOutput && path == r.outputPath {
+		// Rotated parts always start empty. A run killed mid-line left a torn last line, which
+		// must not be continued by the new results; a compressed file can't be cut by line.
+		if !r.config.Compress {
+			if dropped, err := truncateTornLine(path); err != nil {
+				return fmt.Errorf("failed to repair output file: %w", err)
+			} else if dropped > 0 {
+				LogWarn("Removed a partial last line (%d bytes) from %s before appending", dropped, path)
+			}
+		}
+		file, err = appendFileMode(path, r.config.OutputMode)
+	} else {
+		file, err = createFileMode(path, r.config.OutputMode)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	r.outputFile = file
+	r.outputBytes = 0
+	r.headerBytes = 0
+	r.outputLines = 0
+	if info, err := file.Stat(); err == nil && info.Mode().IsRegular() && info.Size() > 0 {
+		// Appending: the header is already there, and --max-output-size counts what the file holds
+		r.outputBytes = info.Size()
+	}
+	if r.config.Compress {
+		r.outputGzip = gzip.NewWriter(file)
+		r.outputWriter = bufio.NewWriterSize(r.outputGzip, outputBufferSize)
+	} else {
+		r.outputWriter = bufio.NewWriterSize(file, outputBufferSize)
+	}
+
+	// Write header if defined in config (parsed and task-json output have their own schema, so skip it there)
+	// On stdout, later --watch batches continue the stream that already started with it
+	appendingStdout := path == stdoutPath && r.config.AppendOutput
+	if r.toolConfig.Header != "" && r.outputParser == nil && r.config.OutputFormat != taskJSONFormat && r.outputBytes == 0 && !appendingStdout {
+		header := r.applyLineEnding(r.toolConfig.Header + "\n")
+		if _, err := r.outputWriter.WriteString(header); err != nil {
+			return fmt.Errorf("failed to write output header: %w", err)
+		}
+		r.outputBytes = int64(len(header))
+		r.headerBytes = r.outputBytes
+		r.bytesWritten.Add(r.outputBytes)
+	}
+	return nil
+}
+
+// truncateTornLine cuts the file at path back to its last newline and returns how many bytes of
+// a partial last line were removed. A missing or empty file is left alone.
+func truncateTornLine(path string) (int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, err
+	}
+	size := info.Size()
+
+	// Read backwards in chunks until a newline is found or the start of the file is reached
+	buf := make([]byte, 64*1024)
+	end := size
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := file.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
 		}
+		end = start
 	}
-	defer cleanupFunc()
 
-	// Check cancellation again before processing
-	select {
-	case <-r.cancelChan:
-		LogWarn("Task %d cancelled during setup.", taskIndex)
-		r.updateTaskStatus(taskIndex, TaskFailed)
-		return
-	default:
+	if end == size {
+		return 0, nil
+	}
+	if err := file.Truncate(end); err != nil {
+		return 0, err
 	}
+	return size - end, nil
+}
 
-	// Tất cả các tool đều được xử lý thông qua config
+// rotateOutputFile closes the current output file and continues in the next numbered one:
+// out.txt, out.txt.1, out.txt.2, ... (the number goes before .gz for compressed output).
+// Writer goroutine only.
+func (r *Runner) rotateOutputFile() error {
+	r.finishOutputFile()
+	r.outputPart++
 
-	tempOutputFile = fmt.Sprintf("temp_output_%d.txt", task.ID)
+	path := rotatedPartPath(r.outputPath, r.outputPart)
+	LogInfo("Output file reached --max-output-size of %d bytes, continuing in %s", r.config.MaxOutputSize, path)
+	return r.openOutputFile(path)
+}
 
-	switch r.toolConfig.Mode {
-	case "multiple":
-		startLine, endLine, err := r.parseLineRange(task.InputData)
-		if err != nil {
-			LogError("Failed to parse line range for task %d: %v", task.ID, err)
-			r.updateTaskStatus(taskIndex, TaskFailed)
-			return
-		}
+// rotatedPartPath names rotated output file n: out.txt -> out.txt.1, out.json.gz -> out.json.1.gz
+func rotatedPartPath(path string, n int) string {
+	if base, ok := strings.CutSuffix(path, ".gz"); ok {
+		return fmt.Sprintf("%s.%d.gz", base, n)
+	}
+	return fmt.Sprintf("%s.%d", path, n)
+}
 
-		chunkFile = fmt.Sprintf("chunk_%d.txt", taskIndex)
-		file, err := os.Create(chunkFile)
-		if err != nil {
-			LogError("Failed to create chunk file for task %d: %v", task.ID, err)
-			r.updateTaskStatus(taskIndex, TaskFailed)
-			return
-		}
-		for i := startLine; i <= endLine && i < len(r.inputLines); i++ {
-			if _, err := file.WriteString(r.inputLines[i] + "\n"); err != nil {
-				file.Close()
-				LogError("Failed to write to chunk file for task %d: %v", task.ID, err)
-				r.updateTaskStatus(taskIndex, TaskFailed)
-				return
-			}
-		}
-		file.Close()
-		inputData = chunkFile
+// nextSplitPart closes the current output part and continues in the next one
+func (r *Runner) nextSplitPart() error {
+	r.finishOutputFile()
+	r.outputPart++
+	path := splitPartPath(r.outputPath, r.outputPart)
+	LogInfo("Output part %d reached %d lines, continuing in %s", r.outputPart-1, r.config.OutputSplit, path)
+	return r.openOutputFile(path)
+}
 
-	case "single":
-		inputData = task.InputData
+// splitPartPath names part n of a split output: out.txt -> out.part1.txt, out.json.gz -> out.part1.json.gz
+func splitPartPath(path string, n int) string {
+	base, compressed := strings.CutSuffix(path, ".gz")
+	ext := filepath.Ext(base)
+	part := fmt.Sprintf("%s.part%d%s", strings.TrimSuffix(base, ext), n, ext)
+	if compressed {
+		part += ".gz"
+	}
+	return part
+}
 
-	default:
-		LogError("Unknown tool mode: %s", r.toolConfig.Mode)
-		r.updateTaskStatus(taskIndex, TaskFailed)
-		return
+// takeLines splits content after its first n lines; rest is empty if it has no more
+func takeLines(content string, n int) (string, string) {
+	end := 0
+	for i := 0; i < n; i++ {
+		next := strings.IndexByte(content[end:], '\n')
+		if next < 0 {
+			return content, ""
+		}
+		end += next + 1
 	}
+	return content[:end], content[end:]
+}
 
-	cmdParts, err := r.configManager.BuildCommand(r.config.Command, inputData, r.config.CommandArgs, tempOutputFile, r.config.Wordlist)
-	if err != nil {
-		LogError("Failed to build command for task %d: %v", task.ID, err)
-		r.updateTaskStatus(taskIndex, TaskFailed)
-		return
+// countLines counts the lines in content, including a last line without a newline
+func countLines(content string) int {
+	lines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		lines++
 	}
+	return lines
+}
 
-	// Decide whether to capture stdout based on tool configuration
-	ignoreStdout := !r.toolConfig.UseStdout
-	r.runTaskWithCommand(taskIndex, cmdParts, ignoreStdout)
+// outputLocation describes where the output went, for log messages. With OutputSplit it must be
+// called after the output is closed.
+func (r *Runner) outputLocation() string {
+	if r.toStdout() {
+		return "stdout"
+	}
+	if r.config.OutputSplit > 0 && r.outputPart > 1 {
+		return fmt.Sprintf("%s ... %s (%d parts)", splitPartPath(r.outputPath, 1), splitPartPath(r.outputPath, r.outputPart), r.outputPart)
+	}
+	if r.config.OutputSplit > 0 {
+		return splitPartPath(r.outputPath, 1)
+	}
+	return r.outputPath
 }
 
-func (r *Runner) writeToOutput(content string) {
+// closeOutputFile stops accepting output, waits for the writer to write everything queued,
+// and closes the file. It is safe to call more than once.
+func (r *Runner) closeOutputFile() {
 	r.outputMutex.Lock()
-	defer r.outputMutex.Unlock()
+	if r.outputClosed {
+		r.outputMutex.Unlock()
+		return
+	}
+	r.outputClosed = true
+	if r.outputChan == nil {
+		// The writer never started
+		r.outputMutex.Unlock()
+		r.finishOutputFile()
+		return
+	}
+	close(r.outputChan)
+	r.outputMutex.Unlock()
 
-	if r.outputFile != nil && content != "" {
-		// Content already has newlines handled by the cleanup function
-		if _, err := r.outputFile.WriteString(content); err != nil {
-			LogError("Failed to write to output file: %v", err)
-		} else {
-			// Ensure data is written to disk immediately
-			r.outputFile.Sync()
+	<-r.outputDone
+}
+
+// finishOutputFile flushes the buffer, finishes the gzip stream if any, then syncs and closes the file
+func (r *Runner) finishOutputFile() {
+	if r.outputFile == nil {
+		return
+	}
+	if err := r.outputWriter.Flush(); err != nil {
+		r.outputWriteFailed(err)
+	}
+	if r.outputGzip != nil {
+		if err := r.outputGzip.Close(); err != nil {
+			LogError("Failed to finish compressed output: %v", err)
 		}
+		r.outputGzip = nil
 	}
+	if r.outputFile != os.Stdout {
+		r.outputFile.Sync() // Ensure all data is written
+		r.outputFile.Close()
+	}
+	r.outputFile = nil
+	r.outputWriter = nil
 }
 
 func (r *Runner) monitor() error {
 	ticker := time.NewTicker(1 * time.Second) // Check more frequently
 	defer ticker.Stop()
 
+	// Progress is logged on its own ticker so completion is still detected promptly when it is slow or off
+	var progress <-chan time.Time
+	if r.config.ProgressInterval > 0 {
+		progressTicker := time.NewTicker(r.config.ProgressInterval)
+		defer progressTicker.Stop()
+		progress = progressTicker.C
+	}
+
+	// A nil channel never fires, so without --max-runtime this case is inert
+	var deadline <-chan time.Time
+	if r.config.MaxRuntime > 0 {
+		timer := time.NewTimer(r.config.MaxRuntime - time.Since(r.startTime))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
 	for {
 		select {
 		case <-ticker.C:
-			if r.checkAllCompleted() {
+			if r.checkAllCompleted(false) {
+				return nil
+			}
+		case <-progress:
+			if r.checkAllCompleted(true) {
 				return nil
 			}
+		case <-deadline:
+			unfinished := r.unfinishedCount()
+			LogWarn("Run stopped: global time limit of %v reached, %d of %d tasks did not finish", r.config.MaxRuntime, unfinished, len(r.tasks))
+			r.cancelTasks()
+			return r.handleInterrupt()
 		case <-r.signalHandler.InterruptChan():
 			LogWarn("Received interrupt signal, cleaning up...")
 			r.cancelTasks()
 			return r.handleInterrupt()
 		case <-r.cancelChan:
 			LogWarn("Cancellation signal received, waiting for tasks to terminate...")
-			// Wait a bit for tasks to notice cancellation before returning
-			time.Sleep(2 * time.Second)
+			r.waitForShutdown()
 			return nil
 		}
 	}
 }
 
-func (r *Runner) checkAllCompleted() bool {
+// checkAllCompleted reports whether every task has finished, logging a progress line when logProgress is set.
+func (r *Runner) checkAllCompleted(logProgress bool) bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	completedCount := 0
 	failedCount := 0
+	timedOutCount := 0
 	runningCount := 0
+	var totalTaskTime time.Duration
 
 	for _, task := range r.tasks {
 		switch task.Status {
 		case TaskCompleted:
 			completedCount++
+			totalTaskTime += task.EndTime.Sub(task.StartTime)
 		case TaskFailed:
 			failedCount++
+		case TaskTimedOut:
+			timedOutCount++
 		case TaskRunning:
 			runningCount++
 		}
 	}
 
 	total := len(r.tasks)
-	LogInfo("Progress: %d/%d completed, %d running, %d failed", completedCount, total, runningCount, failedCount)
+	finishedCount := completedCount + failedCount + timedOutCount
+	percent := 100.0
+	if total > 0 {
+		percent = float64(finishedCount) * 100 / float64(total)
+	}
+	if logProgress {
+		LogInfo("Progress: %d/%d completed (%.1f%%), %d running, %d failed, %d timed out, ETA: %s",
+			completedCount, total, percent, runningCount, failedCount, timedOutCount, r.estimateRemaining(totalTaskTime, completedCount, total-finishedCount))
+	}
+
+	return finishedCount == total
+}
+
+// estimateRemaining estimates time left from the average duration of completed tasks,
+// spread across the configured workers. Returns "unknown" until a task has completed.
+func (r *Runner) estimateRemaining(totalTaskTime time.Duration, completedCount, remainingCount int) string {
+	if remainingCount == 0 {
+		return "0s"
+	}
+	if completedCount == 0 {
+		return "unknown"
+	}
+
+	workers := r.config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	avgTaskTime := totalTaskTime / time.Duration(completedCount)
+	batches := (remainingCount + workers - 1) / workers
+	return (avgTaskTime * time.Duration(batches)).Round(time.Second).String()
+}
+
+// unfinishedCount returns the number of tasks that are still pending or running
+func (r *Runner) unfinishedCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, task := range r.tasks {
+		if task.Status == TaskPending || task.Status == TaskRunning {
+			count++
+		}
+	}
+	return count
+}
+
+// FailedCount returns the number of tasks that failed or timed out
+func (r *Runner) FailedCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, task := range r.tasks {
+		if task.Status == TaskFailed || task.Status == TaskTimedOut {
+			count++
+		}
+	}
+	return count
+}
 
-	return completedCount+failedCount == total
+// cancelled reports whether the run was stopped early: interrupted, out of time, or by a failed
+// task without CollectErrors
+func (r *Runner) cancelled() bool {
+	select {
+	case <-r.cancelChan:
+		return true
+	default:
+		return false
+	}
 }
 
 func (r *Runner) cancelTasks() {
@@ -506,7 +2337,7 @@ func (r *Runner) updateTaskStatus(taskIndex int, status TaskStatus) {
 	defer r.mu.Unlock()
 
 	r.tasks[taskIndex].Status = status
-	if status == TaskCompleted || status == TaskFailed {
+	if status == TaskCompleted || status == TaskFailed || status == TaskTimedOut {
 		r.tasks[taskIndex].EndTime = time.Now()
 	}
 }
@@ -517,37 +2348,45 @@ func (r *Runner) handleInterrupt() error {
 	// Cancel all running tasks
 	r.cancelTasks()
 
-	// Wait for tasks to finish gracefully (with timeout)
-	timeout := time.After(5 * time.Second)
-	ticker := time.NewTicker(500 * time.Millisecond)
+	r.waitForShutdown()
+
+	// Close output file
+	r.closeOutputFile()
+
+	LogInfo("Partial results saved to: %s", r.outputLocation())
+	return nil
+}
+
+// waitForShutdown gives cancelled tasks ShutdownTimeout to stop after their processes were sent
+// SIGTERM, then SIGKILLs every tool process that is still running.
+func (r *Runner) waitForShutdown() {
+	timeout := time.After(r.config.ShutdownTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-timeout:
-			LogWarn("Timeout waiting for tasks to finish, forcing shutdown...")
-			goto cleanup
+			LogWarn("Timeout waiting for tasks to finish after %v, killing remaining processes...", r.config.ShutdownTimeout)
+			r.killAllProcesses()
+			return
 		case <-ticker.C:
 			if r.checkAllTasksStopped() {
 				LogInfo("All tasks stopped gracefully")
-				goto cleanup
+				return
 			}
 		}
 	}
-
-cleanup:
-	// Close output file
-	if r.outputFile != nil {
-		r.outputFile.Sync() // Ensure all data is written
-		r.outputFile.Close()
-		r.outputFile = nil
-	}
-
-	LogInfo("Partial results saved to: %s", r.outputPath)
-	return nil
 }
 
 func (r *Runner) checkAllTasksStopped() bool {
+	r.procMu.Lock()
+	running := len(r.processes)
+	r.procMu.Unlock()
+	if running > 0 {
+		return false
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -559,6 +2398,44 @@ func (r *Runner) checkAllTasksStopped() bool {
 	return true
 }
 
+// trackProcess records a started tool process so shutdown can kill it if it ignores SIGTERM
+func (r *Runner) trackProcess(cmd *exec.Cmd) {
+	r.procMu.Lock()
+	defer r.procMu.Unlock()
+	r.processes[cmd] = struct{}{}
+}
+
+func (r *Runner) untrackProcess(cmd *exec.Cmd) {
+	r.procMu.Lock()
+	defer r.procMu.Unlock()
+	delete(r.processes, cmd)
+}
+
+// stopProcess sends SIGTERM to cmd's process group and SIGKILLs it if it has not exited
+// (exited is closed) within ShutdownTimeout. On Windows the first step already kills it.
+func (r *Runner) stopProcess(cmd *exec.Cmd, exited <-chan struct{}) {
+	terminateProcess(cmd)
+
+	timer := time.NewTimer(r.config.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+	case <-timer.C:
+		LogWarn("Process %d did not exit within %v of SIGTERM, killing it", cmd.Process.Pid, r.config.ShutdownTimeout)
+		killProcess(cmd)
+	}
+}
+
+// killAllProcesses SIGKILLs the process group of every tracked tool process
+func (r *Runner) killAllProcesses() {
+	r.procMu.Lock()
+	defer r.procMu.Unlock()
+	for cmd := range r.processes {
+		LogWarn("Killing process %d", cmd.Process.Pid)
+		killProcess(cmd)
+	}
+}
+
 func (r *Runner) displayPerformanceMetrics() {
 	duration := r.endTime.Sub(r.startTime)
 
@@ -570,6 +2447,7 @@ func (r *Runner) displayPerformanceMetrics() {
 	peakMemMB := float64(r.finalMemStats.Sys) / 1024 / 1024
 
 	LogPerf("=== Performance Metrics ===")
+	LogPerf("Run ID: %s", r.runID)
 	LogPerf("Total execution time: %v", duration)
 	LogPerf("Memory allocated: %.2f MB", memUsedMB)
 	LogPerf("Peak memory usage: %.2f MB", peakMemMB)
@@ -579,6 +2457,7 @@ func (r *Runner) displayPerformanceMetrics() {
 	r.mu.RLock()
 	completedCount := 0
 	failedCount := 0
+	timedOutCount := 0
 	var totalTaskTime time.Duration
 
 	for _, task := range r.tasks {
@@ -590,24 +2469,179 @@ func (r *Runner) displayPerformanceMetrics() {
 			}
 		case TaskFailed:
 			failedCount++
+		case TaskTimedOut:
+			timedOutCount++
 		}
 	}
 	r.mu.RUnlock()
 
 	LogPerf("Tasks completed: %d", completedCount)
 	LogPerf("Tasks failed: %d", failedCount)
+	if timedOutCount > 0 {
+		LogPerf("Tasks timed out: %d", timedOutCount)
+	}
 	LogPerf("Total task time: %v", totalTaskTime)
+	LogPerf("Task input: %d tasks, %.2f MB", len(r.tasks), r.taskInputMB())
 
 	if completedCount > 0 {
 		avgTaskTime := totalTaskTime / time.Duration(completedCount)
 		LogPerf("Average task time: %v", avgTaskTime)
 	}
 
+	linesPerSecond, tasksPerSecond := r.throughput()
+	LogPerf("Throughput: %.1f lines/s, %.2f tasks/s", linesPerSecond, tasksPerSecond)
+	bytesWritten := r.bytesWrittenTotal()
+	LogPerf("Bytes written: %d (%.2f MB)", bytesWritten, float64(bytesWritten)/1024/1024)
+
 	LogPerf("===========================")
 }
 
-// runTaskWithCommand chạy command với external tools
-func (r *Runner) runTaskWithCommand(taskIndex int, cmdParts []string, ignoreStdout bool) {
+// taskInputMB estimates the memory the tasks' input takes: the bytes of the input lines plus the
+// strings and line indexes each task keeps. Go's per-value overhead is left out.
+func (r *Runner) taskInputMB() float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var size int
+	for _, line := range r.inputLines {
+		size += len(line)
+	}
+	for _, task := range r.tasks {
+		size += len(task.InputData) + len(task.WordlistChunk) + len(task.WindowName) + 8*len(task.Lines)
+	}
+	return float64(size) / 1024 / 1024
+}
+
+// throughput returns input lines and finished tasks per second of run time
+func (r *Runner) throughput() (linesPerSecond, tasksPerSecond float64) {
+	seconds := r.endTime.Sub(r.startTime).Seconds()
+	if seconds <= 0 {
+		return 0, 0
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	finished := 0
+	for _, task := range r.tasks {
+		if task.Status == TaskCompleted || task.Status == TaskFailed || task.Status == TaskTimedOut {
+			finished++
+		}
+	}
+	return float64(len(r.inputLines)) / seconds, float64(finished) / seconds
+}
+
+// producedOutput reports whether any results were written (or counted, with CountOnly). The
+// output is closed first so that results still queued for the writer are included.
+func (r *Runner) producedOutput() bool {
+	if r.config.CountOnly {
+		return r.resultCount.Load() > 0
+	}
+	r.closeOutputFile()
+	return r.resultBytes.Load() > 0
+}
+
+// bytesWrittenTotal returns the bytes written to the output (before compression), across rotations
+func (r *Runner) bytesWrittenTotal() int64 {
+	return r.bytesWritten.Load()
+}
+
+// taskEnv returns the extra KEY=VALUE environment entries for a task: the tool's env table
+// followed by --env values (which win on conflict), with {task_id} and {task_name} expanded.
+func (r *Runner) taskEnv(task *Task) []string {
+	if len(r.toolConfig.Env) == 0 && len(r.config.Env) == 0 {
+		return nil
+	}
+
+	replacer := strings.NewReplacer(
+		"{task_id}", strconv.Itoa(task.ID),
+		"{task_name}", task.WindowName,
+	)
+
+	keys := make([]string, 0, len(r.toolConfig.Env))
+	for key := range r.toolConfig.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys)+len(r.config.Env))
+	for _, key := range keys {
+		env = append(env, key+"="+replacer.Replace(r.toolConfig.Env[key]))
+	}
+	for _, entry := range r.config.Env {
+		env = append(env, replacer.Replace(entry))
+	}
+	return env
+}
+
+// newToolCommand creates the command for cmdParts, through the shell unless direct_exec is set,
+// in its own process group and with the task's environment
+func (r *Runner) newToolCommand(cmdParts []string, task *Task) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if r.config.Remote != "" {
+		cmd = r.newRemoteCommand(cmdParts)
+	} else if r.toolConfig.Container != "" {
+		var err error
+		if cmd, err = r.newContainerCommand(cmdParts, task); err != nil {
+			return nil, err
+		}
+	} else if r.toolConfig.DirectExec {
+		if len(cmdParts) == 0 {
+			return nil, fmt.Errorf("empty command")
+		}
+		LogInfo("Running command: %q", cmdParts)
+		cmd = exec.Command(cmdParts[0], cmdParts[1:]...)
+	} else {
+		shell := r.shell()
+		shellFlag := shellCommandFlag(shell)
+		fullCommand := strings.Join(cmdParts, " ")
+		if r.toolConfig.NoGlob || r.config.NoGlob {
+			fullCommand = withoutGlobbing(shell, fullCommand)
+		}
+		LogInfo("Running command: %s %s %s", shell, shellFlag, fullCommand)
+		cmd = exec.Command(shell, shellFlag, fullCommand)
+	}
+
+	setProcessGroup(cmd)
+	// A remote script exports the environment itself, and a container gets it with -e
+	if env := r.taskEnv(task); len(env) > 0 && r.config.Remote == "" && r.toolConfig.Container == "" {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd, nil
+}
+
+// shell returns the configured shell, falling back to the platform default
+func (r *Runner) shell() string {
+	return defaultShell(r.config.Shell)
+}
+
+// defaultShell is shell, or the platform's shell when it is empty
+func defaultShell(shell string) string {
+	if shell != "" {
+		return shell
+	}
+	if runtime.GOOS == "windows" {
+		return "cmd"
+	}
+	return "bash"
+}
+
+// commandShell is the shell the tool's command is quoted for: POSIX sh on the remote host with
+// --remote or inside the tool's container, otherwise the local shell
+func (r *Runner) commandShell() string {
+	switch {
+	case r.config.Remote != "":
+		return remoteShell
+	case r.toolConfig.Container != "":
+		return containerShell
+	}
+	return r.shell()
+}
+
+// runTaskWithCommand chạy command với external tools.
+// Returns the number of stdout and captured stderr lines written to the output file, and whether
+// the run failed with one of the tool's retry_exit_codes and should be run again (attempt counts
+// runs so far); such a failure is not recorded and does not stop the run.
+func (r *Runner) runTaskWithCommand(taskIndex int, cmdParts []string, ignoreStdout bool, attempt int) (int, bool) {
 	r.mu.RLock()
 	task := &r.tasks[taskIndex]
 	r.mu.RUnlock()
@@ -616,77 +2650,118 @@ func (r *Runner) runTaskWithCommand(taskIndex int, cmdParts []string, ignoreStdo
 	select {
 	case <-r.cancelChan:
 		LogWarn("Task %d cancelled before command execution.", task.ID)
-		r.updateTaskStatus(taskIndex, TaskFailed)
-		return
+		r.failTask(taskIndex, cmdParts, errors.New("cancelled before the command started"), nil)
+		return 0, false
 	default:
 	}
 
 	// Create command
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		fullCommand := strings.Join(cmdParts, " ")
-		LogInfo("Running command: cmd /c %s", fullCommand)
-		cmd = exec.Command("cmd", "/c", fullCommand)
-	} else {
-		fullCommand := strings.Join(cmdParts, " ")
-		LogInfo("Running command: bash -c %s", fullCommand)
-		cmd = exec.Command("bash", "-c", fullCommand)
+	cmd, err := r.newToolCommand(cmdParts, task)
+	if err != nil {
+		LogError("%v for task %d", err, task.ID)
+		r.failTask(taskIndex, cmdParts, err, nil)
+		return 0, false
+	}
+	if r.toolConfig.InputMode == InputModeStdin || (r.config.Remote != "" && r.toolConfig.Mode == "multiple") {
+		// The same lines the chunk file has, one per line; a remote script saves them as its chunk file
+		cmd.Stdin = strings.NewReader(strings.Join(r.taskInputLines(task), "\n") + "\n")
 	}
 
-	// Create pipes to capture output
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		LogError("Failed to create stdout pipe for task %d: %v", task.ID, err)
-		r.updateTaskStatus(taskIndex, TaskFailed)
-		return
+	// A remote script prints the tool's output file on stdout, which becomes the local temp output
+	var remoteOutput *os.File
+	if r.config.Remote != "" && ignoreStdout {
+		remoteOutput, err = os.Create(r.tempFileName("temp_output", task.ID))
+		if err != nil {
+			LogError("Failed to create temp output file for task %d: %v", task.ID, err)
+			r.failTask(taskIndex, cmdParts, fmt.Errorf("failed to create temp output file: %w", err), nil)
+			return 0, false
+		}
+		defer remoteOutput.Close()
+	}
+
+	// Create pipes to capture output. With combine_output both streams share one pipe, so lines
+	// arrive in the order the tool wrote them; the stdout reader then handles everything.
+	var stdout, stderr io.ReadCloser
+	var combinedWriter *os.File
+	if r.toolConfig.CombineOutput {
+		stdout, combinedWriter, err = os.Pipe()
+		cmd.Stdout = combinedWriter
+		cmd.Stderr = combinedWriter
+		ignoreStdout = false
+	} else {
+		if remoteOutput != nil {
+			cmd.Stdout = remoteOutput
+		} else {
+			stdout, err = cmd.StdoutPipe()
+		}
+		if err == nil {
+			stderr, err = cmd.StderrPipe()
+		}
 	}
-	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		LogError("Failed to create stderr pipe for task %d: %v", task.ID, err)
-		r.updateTaskStatus(taskIndex, TaskFailed)
-		return
+		LogError("Failed to create output pipes for task %d: %v", task.ID, err)
+		r.failTask(taskIndex, cmdParts, fmt.Errorf("failed to create output pipes: %w", err), nil)
+		return 0, false
 	}
 
 	// Start command
-	if err := cmd.Start(); err != nil {
+	err = cmd.Start()
+	if combinedWriter != nil {
+		// Only the child keeps the write end open, so the reader sees EOF when it exits
+		combinedWriter.Close()
+	}
+	if err != nil {
+		if r.toolConfig.CombineOutput {
+			stdout.Close()
+		}
 		LogError("Failed to start command for task %d: %v", task.ID, err)
-		r.updateTaskStatus(taskIndex, TaskFailed)
-		return
+		r.failTask(taskIndex, cmdParts, fmt.Errorf("failed to start command: %w", err), nil)
+		return 0, false
 	}
 
 	LogTask(task.ID, "Started: %s (PID: %d)", task.WindowName, cmd.Process.Pid)
+	r.trackProcess(cmd)
+	defer r.untrackProcess(cmd)
 
 	// Read output line by line and write directly to shared output file
 	var wg sync.WaitGroup
+	stdoutLines := 0 // Only touched by the stdout goroutine until wg.Wait
 
 	// Channel to signal goroutines to stop
 	done := make(chan struct{})
 	defer close(done)
 
+	write := r.taskWriter(task)
+	stderrRing := newLineRing(r.config.StderrTail) // Only touched by the stderr reader (stdout reader when combined) until wg.Wait
+	stderrLines := 0
+
 	if !ignoreStdout {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			defer stdout.Close()
-			scanner := bufio.NewScanner(stdout)
+			scanner := r.lineScanner(stdout)
 			for scanner.Scan() {
+				// Keep reading after cancellation: output the tool flushes on SIGTERM is still kept
 				select {
 				case <-done:
 					return
-				case <-r.cancelChan:
-					return
 				default:
 					line := scanner.Text()
 					// Write each line immediately to the shared output file, preserving line breaks
-					r.writeToOutput(line + "\n")
+					write(line + "\n")
+					stdoutLines++
+					if r.toolConfig.CombineOutput {
+						stderrRing.Add(line)
+					}
 				}
 			}
 		}()
-	} else {
+	} else if stdout != nil {
 		// Khi tool tự quản lý output, vẫn hiển thị stdout cho user xem progress
 		go func() {
 			defer stdout.Close()
-			scanner := bufio.NewScanner(stdout)
+			scanner := r.lineScanner(stdout)
 			for scanner.Scan() {
 				select {
 				case <-done:
@@ -696,62 +2771,117 @@ func (r *Runner) runTaskWithCommand(taskIndex int, cmdParts []string, ignoreStdo
 				default:
 					line := scanner.Text()
 					// Hiển thị trực tiếp stdout của tool ra console
-					fmt.Println(line)
+					ConsolePrintln(line)
 				}
 			}
 		}()
 	}
 
-	// Capture stderr và hiển thị realtime
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer stderr.Close()
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			select {
-			case <-done:
-				return
-			case <-r.cancelChan:
-				return
-			default:
-				line := scanner.Text()
-				// Hiển thị stderr realtime để user biết có lỗi gì
-				LogTask(task.ID, "[STDERR] %s", line)
+	// Capture stderr và hiển thị realtime, keeping the last lines for the error report
+	if stderr != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer stderr.Close()
+			scanner := r.lineScanner(stderr)
+			for scanner.Scan() {
+				select {
+				case <-done:
+					return
+				default:
+					line := scanner.Text()
+					// Hiển thị stderr realtime để user biết có lỗi gì
+					LogTask(task.ID, "[STDERR] %s", line)
+					stderrRing.Add(line)
+					if r.toolConfig.CaptureStderr {
+						write(line + "\n")
+						stderrLines++
+					}
+				}
 			}
-		}
-	}()
+		}()
+	}
 
-	// Monitor for cancellation and kill process if needed
+	// Monitor for cancellation and stop the process if needed
+	var stopped atomic.Bool
 	go func() {
 		select {
 		case <-r.cancelChan:
 			if cmd.Process != nil {
-				LogWarn("Killing process %d for task %d due to cancellation", cmd.Process.Pid, task.ID)
-				cmd.Process.Kill()
+				stopped.Store(true)
+				LogWarn("Stopping process %d for task %d due to cancellation", cmd.Process.Pid, task.ID)
+				r.stopProcess(cmd, done)
 			}
 		case <-done:
 			// Command finished naturally
 		}
 	}()
 
-	// Wait for command to complete
-	if err := cmd.Wait(); err != nil {
+	// In single mode, kill a line that exceeds its timeout without cancelling the rest of the run
+	var timedOut atomic.Bool
+	if r.toolConfig.Mode == "single" && r.config.LineTimeout > 0 {
+		timer := time.AfterFunc(r.config.LineTimeout, func() {
+			timedOut.Store(true)
+			LogWarn("Task %d exceeded line timeout of %v, stopping process %d", task.ID, r.config.LineTimeout, cmd.Process.Pid)
+			r.stopProcess(cmd, done)
+		})
+		defer timer.Stop()
+	}
+
+	// Drain the output pipes before Wait, which closes them and would drop unread output
+	wg.Wait()
+
+	stderrTail := stderrRing.Lines()
+	r.mu.Lock()
+	task.StderrTail = stderrTail
+	r.mu.Unlock()
+
+	// Wait for command to complete. A tool may exit cleanly on SIGTERM, so a stopped task is
+	// judged by why it was stopped rather than by its exit status.
+	if err := cmd.Wait(); err != nil || timedOut.Load() || stopped.Load() {
+		if err == nil {
+			err = fmt.Errorf("stopped")
+		}
+		if r.toolConfig.Container != "" && (timedOut.Load() || stopped.Load()) {
+			r.removeContainer(task)
+		}
+		if timedOut.Load() {
+			if lines := r.taskLineRange(task); lines != "" {
+				LogTask(task.ID, "timed out after %v (%s)", r.config.LineTimeout, lines)
+			} else {
+				LogTask(task.ID, "timed out after %v", r.config.LineTimeout)
+			}
+			r.recordTaskError(task, cmdParts, fmt.Errorf("timed out after %v", r.config.LineTimeout), stderrTail)
+			r.updateTaskStatus(taskIndex, TaskTimedOut)
+			return stdoutLines + stderrLines, false
+		}
+
 		// Check if error is due to cancellation
 		select {
 		case <-r.cancelChan:
 			LogWarn("Task %d was cancelled", task.ID)
-			r.updateTaskStatus(taskIndex, TaskFailed)
+			r.failTask(taskIndex, cmdParts, fmt.Errorf("cancelled: %w", err), stderrTail)
 		default:
-			LogError("Task %d failed: %v", task.ID, err)
-			r.updateTaskStatus(taskIndex, TaskFailed)
+			if r.shouldRetryExit(err, attempt) {
+				LogTask(task.ID, "exited with status %d, retrying (%d/%d)", exitCode(err), attempt, r.toolConfig.Retries)
+				return stdoutLines + stderrLines, true
+			}
+			LogError("%s failed: %v", r.describeTask(task), err)
+			if r.config.Remote != "" && exitCode(err) == 255 {
+				LogTask(task.ID, "exit status 255 usually means ssh could not connect to %s", r.config.Remote)
+			}
+			if r.toolConfig.Container != "" && exitCode(err) == 125 {
+				LogTask(task.ID, "exit status 125 means docker could not start a container from %s", r.toolConfig.Container)
+			}
+			r.failTask(taskIndex, cmdParts, err, stderrTail)
 			// Signal other tasks to cancel only if it's not already cancelled
-			r.cancelTasks()
+			if !r.config.CollectErrors {
+				r.cancelTasks()
+			}
 		}
 	} else {
-		// Wait for output goroutine to finish before marking as completed
-		wg.Wait()
 		LogTask(task.ID, "completed successfully")
 		r.updateTaskStatus(taskIndex, TaskCompleted)
 	}
+	return stdoutLines + stderrLines, false
 }
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTrailingNewline(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"", ""},
+		{"\n", ""},
+		{"a", "a\n"},
+		{"a\n", "a\n"},
+		{"a\r\n", "a\n"},
+		{"a\n\n", "a\n"},
+		{"a\nb", "a\nb\n"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeTrailingNewline(tt.content); got != tt.want {
+			t.Errorf("normalizeTrailingNewline(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestRestoreBackupRemovesRotatedParts(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.txt")
+	backup := filepath.Join(dir, "out_20240501_142233.txt")
+	files := map[string]string{
+		backup:                     "good\n",
+		output:                     "partial\n",
+		rotatedPartPath(output, 1): "partial\n",
+		rotatedPartPath(output, 2): "partial\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &Runner{
+		outputPath: output,
+		backupPath: backup,
+		outputPart: 2,
+		tasks:      []Task{{Status: TaskFailed}},
+	}
+	r.restoreBackup(nil)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "out.txt" {
+		t.Fatalf("files left after restoring: %v, want only out.txt", entries)
+	}
+	if content, _ := os.ReadFile(output); string(content) != "good\n" {
+		t.Errorf("out.txt holds %q, want the backed-up output", content)
+	}
+}
+
+func TestRotatedPartPath(t *testing.T) {
+	tests := map[string]string{
+		"out.txt":     "out.txt.3",
+		"out.json.gz": "out.json.3.gz",
+	}
+	for path, want := range tests {
+		if got := rotatedPartPath(path, 3); got != want {
+			t.Errorf("rotatedPartPath(%q, 3) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestNameTasksWidth(t *testing.T) {
+	t.Cleanup(func() { SetTaskIDWidth(1) })
+
+	tests := []struct {
+		tasks int
+		width int
+		last  string
+	}{
+		{0, 1, ""},
+		{1, 1, "worker_0"},
+		{10, 1, "worker_9"},
+		{11, 2, "worker_10"},
+	}
+	for _, tt := range tests {
+		r := &Runner{tasks: make([]Task, tt.tasks)}
+		for i := range r.tasks {
+			r.tasks[i].ID = i
+		}
+		r.nameTasks()
+		if taskIDWidth != tt.width {
+			t.Errorf("%d tasks: ID width %d, want %d", tt.tasks, taskIDWidth, tt.width)
+		}
+		if tt.tasks > 0 && r.tasks[tt.tasks-1].WindowName != tt.last {
+			t.Errorf("%d tasks: last task named %q, want %q", tt.tasks, r.tasks[tt.tasks-1].WindowName, tt.last)
+		}
+	}
+}
+
+func TestBackupOutputFileBacksUpSplitParts(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.txt")
+	// An earlier, longer run left three parts
+	for part := 1; part <= 3; part++ {
+		if err := os.WriteFile(splitPartPath(output, part), []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &Runner{outputPath: output, config: RunnerConfig{OutputSplit: 10}}
+	if err := r.backupOutputFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	for part := 1; part <= 3; part++ {
+		if _, err := os.Stat(splitPartPath(output, part)); !os.IsNotExist(err) {
+			t.Errorf("part %d still in place after the backup", part)
+		}
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, "out.part*_*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 {
+		t.Errorf("backups = %v, want one per part", backups)
+	}
+	if r.backupPath != "" {
+		t.Errorf("backupPath = %q, want empty: there was no out.txt", r.backupPath)
+	}
+}
+
+func TestTruncateTornLine(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+		dropped int64
+	}{
+		{"", "", 0},
+		{"a\nb\n", "a\nb\n", 0},
+		{"a\nb\npart", "a\nb\n", 4},
+		{"only partial", "", 12},
+		{"a\n" + strings.Repeat("x", 100*1024), "a\n", 100 * 1024},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "out.txt")
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		dropped, err := truncateTornLine(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := os.ReadFile(path)
+		if string(got) != tt.want || dropped != tt.dropped {
+			t.Errorf("truncateTornLine(%.20q) left %.20q and dropped %d, want %.20q and %d", tt.content, got, dropped, tt.want, tt.dropped)
+		}
+	}
+
+	if dropped, err := truncateTornLine(filepath.Join(t.TempDir(), "missing.txt")); err != nil || dropped != 0 {
+		t.Errorf("missing file: dropped %d, err %v", dropped, err)
+	}
+}
+
+func TestTaskInputMB(t *testing.T) {
+	line := strings.Repeat("x", 512*1024)
+	r := &Runner{
+		inputLines: []string{line, line},
+		tasks:      []Task{{ID: 0}, {ID: 1}},
+	}
+	if got := r.taskInputMB(); got < 1 || got > 1.01 {
+		t.Errorf("taskInputMB() = %.3f, want about 1 MB of input lines", got)
+	}
+}
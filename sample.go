@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// sampleLines returns a random fraction of lines, in their original order. The same seed picks
+// the same lines from the same input, so a sample can be run again. A non-empty input keeps at
+// least one line.
+func sampleLines(lines []string, fraction float64, seed int64) []string {
+	if len(lines) == 0 || fraction >= 1 {
+		return lines
+	}
+	count := int(math.Round(float64(len(lines)) * fraction))
+	count = max(count, 1)
+
+	picked := rand.New(rand.NewSource(seed)).Perm(len(lines))[:count]
+	sort.Ints(picked)
+	sampled := make([]string, count)
+	for i, index := range picked {
+		sampled[i] = lines[index]
+	}
+	return sampled
+}
+
+// sampleInput keeps a random Sample fraction of the input lines, after every other input filter
+func (r *Runner) sampleInput() {
+	total := len(r.inputLines)
+	r.inputLines = sampleLines(r.inputLines, r.config.Sample, r.config.SampleSeed)
+	LogInfo("Sampled %d of %d input lines (%.4g%%, --seed %d)", len(r.inputLines), total, r.config.Sample*100, r.config.SampleSeed)
+}
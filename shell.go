@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// resolveShell picks the shell used to run tool commands. Priority:
+// 1. --shell flag
+// 2. BULKER_SHELL environment variable
+// 3. cmd on Windows, bash on other systems if available, otherwise sh
+// The chosen shell must exist on PATH.
+func resolveShell(flagValue string) (string, error) {
+	shell := flagValue
+	if shell == "" {
+		shell = os.Getenv("BULKER_SHELL")
+	}
+	if shell == "" {
+		if runtime.GOOS == "windows" {
+			shell = "cmd"
+		} else if _, err := exec.LookPath("bash"); err == nil {
+			shell = "bash"
+		} else {
+			shell = "sh"
+		}
+	}
+
+	if _, err := exec.LookPath(shell); err != nil {
+		return "", fmt.Errorf("shell '%s' not found: %w", shell, err)
+	}
+	return shell, nil
+}
+
+// shellBaseName is the shell's lowercased file name without extension, e.g. bash for /bin/bash
+func shellBaseName(shell string) string {
+	return strings.ToLower(strings.TrimSuffix(filepath.Base(shell), filepath.Ext(shell)))
+}
+
+// shellCommandFlag returns the flag that makes shell execute the following string as a command
+func shellCommandFlag(shell string) string {
+	switch shellBaseName(shell) {
+	case "cmd":
+		return "/c"
+	case "pwsh", "powershell":
+		return "-Command"
+	default:
+		return "-c"
+	}
+}
+
+// shellQuote quotes value so the given shell passes it to the command as one literal argument.
+// Values made only of characters that are never special to a shell are returned unchanged.
+func shellQuote(shell, value string) string {
+	if value != "" && strings.IndexFunc(value, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("_-.,/:@+=", c))
+	}) == -1 {
+		return value
+	}
+
+	switch shellCommandFlag(shell) {
+	case "/c":
+		// cmd: double quotes stop &, |, < and > from being interpreted, but not %VAR% expansion.
+		// Each % is put outside the quotes as ^%, so no %...% pair can name a variable.
+		value = strings.ReplaceAll(value, `"`, `""`)
+		return `"` + strings.ReplaceAll(value, "%", `"^%"`) + `"`
+	case "-Command":
+		// PowerShell: single-quoted strings are literal, a quote is escaped by doubling it
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	default:
+		// POSIX shells: single-quoted strings are literal, close and reopen around a quote
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	}
+}
+
+// posixShells take set -f to stop globbing
+var posixShells = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "ash": true, "busybox": true,
+	"ksh": true, "mksh": true, "oksh": true, "yash": true, "posh": true,
+}
+
+// noGlobCommand returns the command that stops shell from expanding * and ?, or "" for cmd and
+// PowerShell, which do not glob arguments. It returns false for shells it does not know, such as
+// fish. zsh ignores set -f (-f is not its no-glob option), so it gets setopt noglob.
+func noGlobCommand(shell string) (string, bool) {
+	name := shellBaseName(shell)
+	switch {
+	case shellCommandFlag(shell) != "-c":
+		return "", true
+	case name == "zsh":
+		return "setopt noglob", true
+	case posixShells[name]:
+		return "set -f", true
+	}
+	return "", false
+}
+
+// withoutGlobbing prefixes command so the shell leaves unquoted * and ? alone instead of
+// expanding them against the working directory. NewRunner rejects no_glob for shells that
+// noGlobCommand does not know.
+func withoutGlobbing(shell, command string) string {
+	prefix, _ := noGlobCommand(shell)
+	if prefix == "" {
+		return command
+	}
+	return prefix + "; " + command
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// hostileInputs are values a target list or -e argument may hold that a shell would act on
+var hostileInputs = []string{
+	"; rm -rf ~",
+	"$(id)",
+	"`id`",
+	"it's",
+	"a\nb",
+	"%PATH%",
+	"a & b | c > d",
+	`"quoted" \ back`,
+	"*.txt",
+	"",
+}
+
+func TestShellQuotePOSIX(t *testing.T) {
+	for _, shell := range []string{"bash", "sh"} {
+		if _, err := exec.LookPath(shell); err != nil {
+			t.Logf("%s not found, skipping", shell)
+			continue
+		}
+		for _, value := range hostileInputs {
+			// The shell must hand the quoted value to printf unchanged
+			out, err := exec.Command(shell, "-c", "printf '%s' "+shellQuote(shell, value)).Output()
+			if err != nil {
+				t.Errorf("%s: running quoted %q: %v", shell, value, err)
+				continue
+			}
+			if string(out) != value {
+				t.Errorf("%s: shellQuote(%q) was read back as %q", shell, value, out)
+			}
+		}
+	}
+}
+
+func TestShellQuoteWindows(t *testing.T) {
+	tests := []struct {
+		shell string
+		value string
+		want  string
+	}{
+		{"cmd", "; rm -rf ~", `"; rm -rf ~"`},
+		{"cmd", "a & b | c > d", `"a & b | c > d"`},
+		{"cmd", `say "hi"`, `"say ""hi"""`},
+		{"cmd", "%PATH%", `""^%"PATH"^%""`},
+		{"cmd", "50%", `"50"^%""`},
+		{"cmd.exe", "$(id)", `"$(id)"`},
+		{"powershell", "$(id)", `'$(id)'`},
+		{"pwsh", "it's", `'it''s'`},
+		{"pwsh", "`id`; %PATH%", "'`id`; %PATH%'"},
+		{"pwsh", "a\nb", "'a\nb'"},
+		{"cmd", "plain-value.txt", "plain-value.txt"},
+		{"pwsh", "", "''"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.shell, tt.value); got != tt.want {
+			t.Errorf("shellQuote(%q, %q) = %s, want %s", tt.shell, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCommandDropsEmptyValues(t *testing.T) {
+	cm := &ConfigManager{config: Config{Tools: map[string]ToolConfig{
+		"ffuf": {Name: "ffuf", Mode: "single", Command: "ffuf -u {input} -w {wordlist} -o {output}"},
+	}}}
+
+	got, err := cm.BuildCommand("ffuf", "http://x/FUZZ", "", nil, "", "", "bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ffuf", "-u", "http://x/FUZZ", "-w", "-o"}
+	if len(got) != len(want) {
+		t.Fatalf("BuildCommand = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("BuildCommand = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestWithoutGlobbingKeepsAsterisks(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, shell := range []string{"bash", "sh", "zsh"} {
+		if _, err := exec.LookPath(shell); err != nil {
+			t.Logf("%s not found, skipping", shell)
+			continue
+		}
+		cmd := exec.Command(shell, "-c", withoutGlobbing(shell, "printf '%s\\n' FUZZ *.txt"))
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		if got := string(out); got != "FUZZ\n*.txt\n" {
+			t.Errorf("%s: got %q, want the * kept literal", shell, got)
+		}
+	}
+}
+
+func TestNoGlobCommand(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  string
+		ok    bool
+	}{
+		{"bash", "set -f", true},
+		{"/bin/sh", "set -f", true},
+		{"/usr/bin/zsh", "setopt noglob", true},
+		{"cmd", "", true},
+		{"pwsh", "", true},
+		{"fish", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := noGlobCommand(tt.shell)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("noGlobCommand(%q) = %q, %v, want %q, %v", tt.shell, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -24,6 +24,8 @@ func NewSignalHandler() *SignalHandler {
 func (sh *SignalHandler) Setup(cleanupFunc func() error) {
 	sh.cleanupFunc = cleanupFunc
 	signal.Notify(sh.interruptChan, os.Interrupt, syscall.SIGTERM)
+	// Turn SIGPIPE into EPIPE write errors so a closed stdout (e.g. `| head`) is handled gracefully
+	signal.Ignore(syscall.SIGPIPE)
 }
 
 func (sh *SignalHandler) InterruptChan() <-chan os.Signal {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// defaultTimeRegex takes the first whitespace-separated field of a line as its timestamp
+const defaultTimeRegex = `^(\S+)`
+
+// sinceFilter keeps input lines whose timestamp is after a cutoff, for running over growing log files
+type sinceFilter struct {
+	cutoff  time.Time
+	pattern *regexp.Regexp
+	layout  string
+}
+
+// newSinceFilter builds the --since filter. since is either a timestamp in layout or a duration
+// (e.g. "24h") counted back from now. timeRegex must have one capture group holding the
+// timestamp; layout is a Go time layout and defaults to RFC 3339.
+func newSinceFilter(since, timeRegex, layout string, now time.Time) (*sinceFilter, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	if timeRegex == "" {
+		timeRegex = defaultTimeRegex
+	}
+
+	pattern, err := regexp.Compile(timeRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --time-regex: %w", err)
+	}
+	if pattern.NumSubexp() < 1 {
+		return nil, fmt.Errorf("--time-regex needs a capture group around the timestamp")
+	}
+
+	cutoff, err := time.Parse(layout, since)
+	if err != nil {
+		duration, durationErr := time.ParseDuration(since)
+		if durationErr != nil {
+			return nil, fmt.Errorf("invalid --since %q: not a duration or a time in layout %q", since, layout)
+		}
+		cutoff = now.Add(-duration)
+	}
+
+	return &sinceFilter{cutoff: cutoff, pattern: pattern, layout: layout}, nil
+}
+
+// keep reports whether line has a timestamp after the cutoff. ok is false when no timestamp
+// could be found or parsed.
+func (f *sinceFilter) keep(line string) (keep bool, ok bool) {
+	match := f.pattern.FindStringSubmatch(line)
+	if match == nil {
+		return false, false
+	}
+	timestamp, err := time.Parse(f.layout, match[1])
+	if err != nil {
+		return false, false
+	}
+	return timestamp.After(f.cutoff), true
+}
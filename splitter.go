@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// parseByteSize parses a human-readable size such as "512", "64KB", "10MB" or "1.5GB"
+// into a number of bytes. Units are case-insensitive and use 1024 as the base.
+func parseByteSize(sizeStr string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(sizeStr))
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"GB", 1024 * 1024 * 1024},
+		{"MB", 1024 * 1024},
+		{"KB", 1024},
+		{"G", 1024 * 1024 * 1024},
+		{"M", 1024 * 1024},
+		{"K", 1024},
+		{"B", 1},
+	}
+
+	multiplier := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.multiplier
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", sizeStr)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("size must be greater than zero: %s", sizeStr)
+	}
+
+	return int64(value * multiplier), nil
+}
+
+// splitLinesByBytes groups consecutive lines into ranges whose total size (including
+// the trailing newline written to the chunk file) stays within maxBytes. A line is never
+// split: a single line larger than maxBytes becomes its own chunk. The final range takes
+// the remainder. Each returned range is [start, end] with end inclusive.
+func splitLinesByBytes(lines []string, maxBytes int64) [][2]int {
+	var ranges [][2]int
+	if len(lines) == 0 || maxBytes <= 0 {
+		return ranges
+	}
+
+	start := 0
+	var current int64
+	for i, line := range lines {
+		lineSize := int64(len(line) + 1)
+		if current > 0 && current+lineSize > maxBytes {
+			ranges = append(ranges, [2]int{start, i - 1})
+			start = i
+			current = 0
+		}
+		current += lineSize
+	}
+	ranges = append(ranges, [2]int{start, len(lines) - 1})
+
+	return ranges
+}
+
+// splitLinesEvenly divides totalLines into at most chunkCount contiguous ranges of equal size
+// (the last range may be shorter). Each returned range is [start, end] with end inclusive.
+func splitLinesEvenly(totalLines, chunkCount int) [][2]int {
+	var ranges [][2]int
+	if totalLines == 0 {
+		return ranges
+	}
+	if chunkCount < 1 {
+		chunkCount = 1
+	}
+
+	chunkSize := totalLines / chunkCount
+	if totalLines%chunkCount != 0 {
+		chunkSize++
+	}
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+
+	for startLine := 0; startLine < totalLines; startLine += chunkSize {
+		endLine := startLine + chunkSize
+		if endLine > totalLines {
+			endLine = totalLines
+		}
+		ranges = append(ranges, [2]int{startLine, endLine - 1})
+	}
+	return ranges
+}
+
+// parseShard parses a shard spec "i/n" (0 <= i < n) as used by --shard
+func parseShard(spec string) (int, int, error) {
+	parts := strings.Split(strings.TrimSpace(spec), "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected index/count such as 2/5", spec)
+	}
+	index, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard index: %s", parts[0])
+	}
+	count, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard count: %s", parts[1])
+	}
+	if count < 1 || index < 0 || index >= count {
+		return 0, 0, fmt.Errorf("shard index must be between 0 and %d", count-1)
+	}
+	return index, count, nil
+}
+
+// inShard reports whether line belongs to shard index of count. The assignment uses the
+// 32-bit FNV-1a hash of the line's bytes, so it is the same on every machine and platform.
+func inShard(line string, index, count int) bool {
+	h := fnv.New32a()
+	h.Write([]byte(line))
+	return int(h.Sum32()%uint32(count)) == index
+}
+
+// prioritizeLines returns lines with those in priority moved to the front. Both groups keep
+// their original relative order.
+func prioritizeLines(lines []string, priority map[string]struct{}) []string {
+	first := make([]string, 0, len(priority))
+	rest := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if _, ok := priority[line]; ok {
+			first = append(first, line)
+		} else {
+			rest = append(rest, line)
+		}
+	}
+	return append(first, rest...)
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// openSyslog is not supported on this platform
+func openSyslog(tag string) (syslogSink, error) {
+	return nil, fmt.Errorf("syslog logging is not supported on %s", runtime.GOOS)
+}
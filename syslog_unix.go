@@ -0,0 +1,12 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"log/syslog"
+)
+
+// openSyslog connects to the local syslog daemon (journald picks this up under systemd)
+func openSyslog(tag string) (syslogSink, error) {
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// lineRing keeps the last cap(lines) lines written to it
+type lineRing struct {
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLineRing(size int) *lineRing {
+	return &lineRing{lines: make([]string, size)}
+}
+
+func (lr *lineRing) Add(line string) {
+	if len(lr.lines) == 0 {
+		return
+	}
+	lr.lines[lr.next] = line
+	lr.next = (lr.next + 1) % len(lr.lines)
+	if lr.next == 0 {
+		lr.full = true
+	}
+}
+
+// Lines returns the stored lines, oldest first
+func (lr *lineRing) Lines() []string {
+	if !lr.full {
+		return append([]string(nil), lr.lines[:lr.next]...)
+	}
+	return append(append([]string(nil), lr.lines[lr.next:]...), lr.lines[:lr.next]...)
+}
+
+// TaskError records why a task failed, with enough detail to re-run just that task
+type TaskError struct {
+	TaskID   int      `json:"task_id"`
+	Name     string   `json:"name"`
+	Lines    string   `json:"lines,omitempty"` // Input line range of a multiple-mode chunk, e.g. "lines 101-200"
+	Input    []string `json:"input"`
+	Command  string   `json:"command"`
+	ExitCode int      `json:"exit_code"` // -1 when the process did not exit normally (killed, timed out)
+	Error    string   `json:"error"`
+	Stderr   []string `json:"stderr,omitempty"`
+	// ChunkFile is where the task's input was saved with --failed-chunks
+	ChunkFile string `json:"chunk_file,omitempty"`
+	// The run's tool settings, for `bulker rerun`. Paths are absolute.
+	Tool     string   `json:"tool,omitempty"`
+	Config   string   `json:"config,omitempty"`
+	Profile  string   `json:"profile,omitempty"`
+	Mode     string   `json:"mode,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	Wordlist string   `json:"wordlist,omitempty"`
+	Output   string   `json:"output,omitempty"`
+}
+
+// exitCode extracts the process exit code from a cmd.Wait error, or -1 if there is none
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// errTaskUnfinished is the error reported for tasks a stopped run never ran or finished
+var errTaskUnfinished = errors.New("not finished: the run was stopped")
+
+// recordTaskError stores a failure for the end-of-run report, with the stderr tail of the failed run
+func (r *Runner) recordTaskError(task *Task, cmdParts []string, err error, stderr []string) {
+	taskErr := r.newTaskError(task, cmdParts, err, stderr)
+	if r.config.FailedChunksDir != "" {
+		path, err := r.saveFailedChunk(task, taskErr.Input)
+		if err != nil {
+			LogError("Failed to save input of task %d: %v", task.ID, err)
+		} else {
+			taskErr.ChunkFile = path
+		}
+	}
+
+	r.errorsMu.Lock()
+	r.taskErrors = append(r.taskErrors, taskErr)
+	r.errorsMu.Unlock()
+}
+
+// newTaskError describes a failed task together with the run's settings, for `bulker rerun`
+func (r *Runner) newTaskError(task *Task, cmdParts []string, err error, stderr []string) TaskError {
+	taskErr := TaskError{
+		TaskID:   task.ID,
+		Name:     task.WindowName,
+		Lines:    r.taskLineRange(task),
+		Input:    r.taskInputLines(task),
+		Command:  strings.Join(cmdParts, " "),
+		ExitCode: exitCode(err),
+		Error:    err.Error(),
+		Stderr:   stderr,
+		Tool:     r.config.Command,
+		Profile:  r.config.Profile,
+		Mode:     r.config.Mode,
+		Args:     r.config.CommandArgs,
+	}
+	if r.config.RawTool != nil {
+		// A --raw command is not in any config file, so there is nothing to rerun by name
+		taskErr.Tool = ""
+	}
+	if r.config.ConfigFile != "" {
+		taskErr.Config = absPath(r.config.ConfigFile)
+	}
+	if r.config.Wordlist != "" {
+		taskErr.Wordlist = absPath(r.config.Wordlist)
+	}
+	if !r.toStdout() {
+		taskErr.Output = absPath(r.outputPath)
+	}
+	return taskErr
+}
+
+// unfinishedTaskErrors describes the tasks that were still pending or running when the run
+// stopped, so that a rerun of --errors-file covers the input they never processed
+func (r *Runner) unfinishedTaskErrors() []TaskError {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var taskErrors []TaskError
+	for i := range r.tasks {
+		if task := &r.tasks[i]; task.Status == TaskPending || task.Status == TaskRunning {
+			taskErrors = append(taskErrors, r.newTaskError(task, nil, errTaskUnfinished, nil))
+		}
+	}
+	return taskErrors
+}
+
+// failTask marks a task failed and records why for the end-of-run report and --errors-file.
+// cmdParts is nil when the task failed before its command was built.
+func (r *Runner) failTask(taskIndex int, cmdParts []string, err error, stderr []string) {
+	r.mu.RLock()
+	task := &r.tasks[taskIndex]
+	r.mu.RUnlock()
+	r.recordTaskError(task, cmdParts, err, stderr)
+	r.updateTaskStatus(taskIndex, TaskFailed)
+}
+
+// reportTaskErrors prints collected failures and, with ErrorsFile, writes them as JSON lines
+// together with the tasks a stopped run left unfinished. The file is written even when nothing
+// failed, so a report rerun in place ends up empty.
+func (r *Runner) reportTaskErrors() {
+	r.errorsMu.Lock()
+	taskErrors := r.taskErrors
+	r.errorsMu.Unlock()
+
+	var unfinished []TaskError
+	if r.config.ErrorsFile != "" {
+		unfinished = r.unfinishedTaskErrors()
+	}
+
+	if len(taskErrors) == 0 && len(unfinished) == 0 {
+		if r.config.ErrorsFile != "" {
+			if err := writeTaskErrors(r.config.ErrorsFile, nil); err != nil {
+				LogError("Failed to write errors file: %v", err)
+			}
+		}
+		return
+	}
+
+	if len(taskErrors) > 0 {
+		LogWarn("=== Failed Tasks ===")
+	}
+	for _, taskErr := range taskErrors {
+		if taskErr.Lines != "" {
+			LogWarn("Task %d (%s): %s, %s", taskErr.TaskID, taskErr.Name, taskErr.Error, taskErr.Lines)
+		} else {
+			LogWarn("Task %d (%s): %s, %d input lines", taskErr.TaskID, taskErr.Name, taskErr.Error, len(taskErr.Input))
+		}
+		if taskErr.ChunkFile != "" {
+			LogWarn("    input saved to %s", taskErr.ChunkFile)
+		}
+		for _, line := range taskErr.Stderr {
+			LogWarn("    %s", line)
+		}
+	}
+
+	if r.config.ErrorsFile == "" {
+		return
+	}
+	if len(unfinished) > 0 {
+		LogWarn("The run stopped with %d tasks unfinished; they are listed in the errors file too", len(unfinished))
+	}
+	if err := writeTaskErrors(r.config.ErrorsFile, append(append([]TaskError(nil), taskErrors...), unfinished...)); err != nil {
+		LogError("Failed to write errors file: %v", err)
+		return
+	}
+	LogInfo("Error report written to: %s", r.config.ErrorsFile)
+}
+
+// taskLineRange describes the input lines of a multiple-mode chunk task, numbered from 1 in the
+// input after filtering, e.g. "lines 101-200". It is empty for single-mode and wordlist tasks.
+func (r *Runner) taskLineRange(task *Task) string {
+	if r.toolConfig.Mode != "multiple" || task.WordlistChunk != "" {
+		return ""
+	}
+	lineIndexes, err := r.taskLineIndexes(task)
+	if err != nil || len(lineIndexes) == 0 {
+		return ""
+	}
+
+	first, last := lineIndexes[0], lineIndexes[len(lineIndexes)-1]
+	if last-first+1 == len(lineIndexes) {
+		return fmt.Sprintf("lines %d-%d", first+1, last+1)
+	}
+	// round_robin chunks are not contiguous
+	return fmt.Sprintf("%d lines between %d and %d", len(lineIndexes), first+1, last+1)
+}
+
+// describeTask names a task in failure logs, with its line range when it has one
+func (r *Runner) describeTask(task *Task) string {
+	if lines := r.taskLineRange(task); lines != "" {
+		return fmt.Sprintf("Task %d (%s)", task.ID, lines)
+	}
+	return fmt.Sprintf("Task %d", task.ID)
+}
+
+// saveFailedChunk writes a failed task's input lines to FailedChunksDir, ready to be passed
+// back to bulker with -i, and returns the file's path
+func (r *Runner) saveFailedChunk(task *Task, input []string) (string, error) {
+	if err := r.createOutputDir(r.config.FailedChunksDir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(r.config.FailedChunksDir, fmt.Sprintf("task_%d.txt", task.ID))
+	file, err := createFileMode(path, r.config.OutputMode)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, line := range input {
+		writer.WriteString(line + "\n")
+	}
+	if err := writer.Flush(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeTaskErrors writes one JSON object per failed task to path
+func writeTaskErrors(path string, taskErrors []TaskError) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false) // Commands are full of > and &
+	for _, taskErr := range taskErrors {
+		if err := encoder.Encode(taskErr); err != nil {
+			return fmt.Errorf("failed to encode task %d: %w", taskErr.TaskID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Defaults for collapse_output records: input<TAB>result1|result2
+const (
+	defaultCollapseDelimiter = "\t"
+	defaultCollapseSeparator = "|"
+)
+
+// taskJSONFormat is the --format value that writes one JSON object per task
+const taskJSONFormat = "task-json"
+
+// taskOutput collects one task's output lines so they can be written together when the task
+// ends, for collapse_output and --format task-json. Lines may come from the stdout and stderr
+// readers at the same time.
+type taskOutput struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// add takes a block of output in place of writeToOutput, keeping its non-empty lines
+func (c *taskOutput) add(content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			c.lines = append(c.lines, line)
+		}
+	}
+}
+
+// reset drops the lines collected so far, before a task is run again
+func (c *taskOutput) reset() {
+	c.mu.Lock()
+	c.lines = nil
+	c.mu.Unlock()
+}
+
+// Lines returns a copy of the collected lines
+func (c *taskOutput) Lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+// collectsTaskOutput reports whether task output is held until the task ends instead of being
+// written as it arrives
+func (r *Runner) collectsTaskOutput() bool {
+	return r.toolConfig.CollapseOutput || r.config.OutputFormat == taskJSONFormat
+}
+
+// taskWriter returns where a task's output goes: straight to the output file, or into the
+// task's collected output
+func (r *Runner) taskWriter(task *Task) func(string) {
+	if task.collected == nil {
+		return r.writeToOutput
+	}
+	return task.collected.add
+}
+
+// writeTaskOutput writes a finished task's collected output as one record
+func (r *Runner) writeTaskOutput(task *Task) {
+	if r.config.OutputFormat == taskJSONFormat {
+		r.writeTaskJSON(task)
+		return
+	}
+
+	lines := task.collected.Lines()
+	if len(lines) == 0 {
+		return
+	}
+	delimiter := r.toolConfig.CollapseDelimiter
+	if delimiter == "" {
+		delimiter = defaultCollapseDelimiter
+	}
+	separator := r.toolConfig.CollapseSeparator
+	if separator == "" {
+		separator = defaultCollapseSeparator
+	}
+	r.writeToOutput(r.taskInput(task) + delimiter + strings.Join(lines, separator) + "\n")
+}
+
+// TaskRecord is the object written per task with --format task-json
+type TaskRecord struct {
+	TaskID int      `json:"task_id"`
+	Input  []string `json:"input"`
+	Lines  string   `json:"lines,omitempty"` // Input line range of a multiple-mode chunk
+	Status string   `json:"status"`
+	// Output holds the task's output lines, or their parsed records for tools with a parser
+	Output []json.RawMessage `json:"output"`
+}
+
+// writeTaskJSON writes a finished task as one JSON line. The object is queued as a single
+// block, so records of concurrent tasks never interleave.
+func (r *Runner) writeTaskJSON(task *Task) {
+	r.mu.RLock()
+	status := task.Status
+	r.mu.RUnlock()
+
+	record := TaskRecord{
+		TaskID: task.ID,
+		Input:  r.taskInputLines(task),
+		Lines:  r.taskLineRange(task),
+		Status: taskStatusName(status),
+		Output: []json.RawMessage{},
+	}
+	for _, line := range task.collected.Lines() {
+		if r.config.StripANSI {
+			line = StripANSI(line)
+		}
+		if r.outputParser != nil {
+			parsed, ok := r.outputParser(strings.TrimSpace(line))
+			if !ok {
+				continue
+			}
+			record.Output = append(record.Output, json.RawMessage(formatRecord(parsed, "json")))
+			continue
+		}
+		data, _ := json.Marshal(line)
+		record.Output = append(record.Output, data)
+	}
+
+	var builder strings.Builder
+	encoder := json.NewEncoder(&builder)
+	encoder.SetEscapeHTML(false) // Output is full of URLs with & in them
+	if err := encoder.Encode(record); err != nil {
+		LogError("Failed to encode output of task %d: %v", task.ID, err)
+		return
+	}
+	r.writeRawOutput(builder.String())
+}
+
+// taskStatusName is how a task status appears in task-json records
+func taskStatusName(status TaskStatus) string {
+	switch status {
+	case TaskCompleted:
+		return "completed"
+	case TaskFailed:
+		return "failed"
+	case TaskTimedOut:
+		return "timed_out"
+	case TaskRunning:
+		return "running"
+	}
+	return "pending"
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// memorySampleInterval is how often the memory throttle samples runtime memory usage
+const memorySampleInterval = 500 * time.Millisecond
+
+// startMemoryThrottle samples memory usage in the background and adapts concurrency to MemLimit.
+// While usage is above the budget it holds back one slot of the limiter per sample, so fewer tasks
+// can be launched (always leaving at least one slot for progress). Once usage drops below 80% of
+// the budget it gives the slots back one at a time.
+// The returned function stops the sampler and releases any slots still held.
+func (r *Runner) startMemoryThrottle(limiter *workerLimiter) func() {
+	if r.config.MemLimit <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	resumeBelow := uint64(float64(r.config.MemLimit) * 0.8)
+
+	go func() {
+		defer close(done)
+		defer limiter.unholdAll()
+
+		held := 0
+		ticker := time.NewTicker(memorySampleInterval)
+		defer ticker.Stop()
+
+		var memStats runtime.MemStats
+		for {
+			select {
+			case <-stop:
+				return
+			case <-r.cancelChan:
+				return
+			case <-ticker.C:
+			}
+
+			runtime.ReadMemStats(&memStats)
+			switch {
+			case memStats.Alloc > uint64(r.config.MemLimit):
+				// Running tasks keep going; the lower capacity applies as they finish
+				if capacity, ok := limiter.hold(); ok {
+					held++
+					LogWarn("Memory usage %.2f MB over limit, reducing workers to %d",
+						float64(memStats.Alloc)/1024/1024, capacity)
+				}
+			case memStats.Alloc < resumeBelow && held > 0:
+				capacity := limiter.unhold()
+				held--
+				LogInfo("Memory usage back to %.2f MB, increasing workers to %d",
+					float64(memStats.Alloc)/1024/1024, capacity)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
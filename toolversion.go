@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// versionCommandTimeout bounds how long a tool's version_command may take at startup
+const versionCommandTimeout = 10 * time.Second
+
+// versionPattern finds a version such as 1.3.7, v2.0 or 1.6.0-dev in a tool's version output;
+// the first match is taken
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseVersion returns the major, minor and patch numbers of the first version in text
+func parseVersion(text string) ([3]int, bool) {
+	var version [3]int
+	match := versionPattern.FindStringSubmatch(text)
+	if match == nil {
+		return version, false
+	}
+	for i, part := range match[1:] {
+		if part != "" {
+			version[i], _ = strconv.Atoi(part)
+		}
+	}
+	return version, true
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, the same as or newer than b
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkToolVersion runs the tool's version_command and returns an error if the version it
+// prints is older than min_version or cannot be found. Tools without min_version always pass.
+func checkToolVersion(name string, toolConfig ToolConfig, shell string) error {
+	if toolConfig.MinVersion == "" {
+		return nil
+	}
+	minimum, ok := parseVersion(toolConfig.MinVersion)
+	if !ok {
+		return fmt.Errorf("tool '%s' has an invalid min_version '%s' (expected e.g. 1.3.0)", name, toolConfig.MinVersion)
+	}
+	if toolConfig.VersionCommand == "" {
+		return fmt.Errorf("tool '%s' sets min_version but no version_command to check it with", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionCommandTimeout)
+	defer cancel()
+	// Many tools print their version on stderr, so both are searched
+	out, err := exec.CommandContext(ctx, shell, shellCommandFlag(shell), toolConfig.VersionCommand).CombinedOutput()
+	version, found := parseVersion(string(out))
+	if !found {
+		if err != nil {
+			return fmt.Errorf("could not check the version of tool '%s': %s failed: %v", name, toolConfig.VersionCommand, err)
+		}
+		return fmt.Errorf("could not check the version of tool '%s': no version in the output of %s", name, toolConfig.VersionCommand)
+	}
+
+	if compareVersions(version, minimum) < 0 {
+		return fmt.Errorf("tool '%s' is version %d.%d.%d, older than its min_version %s; flags in its command or auto_optimizations may not work", name, version[0], version[1], version[2], toolConfig.MinVersion)
+	}
+	LogInfo("Tool '%s' is version %d.%d.%d (min_version %s)", name, version[0], version[1], version[2], toolConfig.MinVersion)
+	return nil
+}
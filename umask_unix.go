@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// setUmask sets the process umask used for every file and directory bulker and its tools create
+func setUmask(mask int) error {
+	syscall.Umask(mask)
+	return nil
+}
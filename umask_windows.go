@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+// setUmask is not supported on Windows, which has no umask
+func setUmask(mask int) error {
+	return fmt.Errorf("--umask is not supported on Windows")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// inputWatcher follows an input file after the first run, like tail -f: each time complete lines
+// are appended, it runs them as a new batch that appends to the output.
+type inputWatcher struct {
+	config    RunnerConfig // Settings for each batch; InputFile is the watched file
+	interval  time.Duration
+	offset    int64               // Bytes of the input file already read
+	processed map[string]struct{} // Lines already run (as read and as processed), never run again
+	tempDir   string
+}
+
+// watchInput keeps processing lines appended to config.InputFile after first has run, until
+// interrupted. Lines that were already processed, by the first run or an earlier batch, are skipped,
+// so a rewritten or rotated input file does not run everything again.
+func watchInput(config RunnerConfig, first *Runner, interval time.Duration) error {
+	tempDir, err := os.MkdirTemp("", "bulker_watch_")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	w := &inputWatcher{config: config, interval: interval, processed: make(map[string]struct{}), tempDir: tempDir}
+	w.markProcessed(first.inputLines)
+
+	// The first poll re-reads the file from the start: lines appended while the first run was
+	// reading are caught there, the rest are skipped as processed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	LogInfo("Watching %s for new lines (every %v, Ctrl-C to stop)", config.InputFile, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for batch := 1; ; {
+		select {
+		case <-signals:
+			LogInfo("Stopped watching %s", config.InputFile)
+			return nil
+		case <-ticker.C:
+		}
+
+		lines, err := w.readNewLines()
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		LogInfo("Watch batch %d: %d new lines in %s", batch, len(lines), config.InputFile)
+		stopped, err := w.runBatch(batch, lines)
+		if err != nil {
+			return err
+		}
+		batch++
+
+		// A batch stopped by Ctrl-C (or a failed task without --collect-errors) ends the watch
+		if stopped {
+			LogInfo("Stopped watching %s", config.InputFile)
+			return nil
+		}
+	}
+}
+
+// readNewLines returns the complete lines appended to the input file since the last read that have
+// not been processed yet. A partly written last line is left for the next read. If the file shrank
+// (truncated or replaced), it is read again from the start.
+func (w *inputWatcher) readNewLines() ([]string, error) {
+	file, err := os.Open(w.config.InputFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Being replaced; try again on the next tick
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat input file: %w", err)
+	}
+	if info.Size() < w.offset {
+		LogInfo("%s got shorter, reading it again from the start", w.config.InputFile)
+		w.offset = 0
+	}
+	if info.Size() == w.offset {
+		return nil, nil
+	}
+
+	if _, err := file.Seek(w.offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(file, info.Size()-w.offset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return nil, nil
+	}
+	w.offset += int64(end + 1)
+
+	var lines []string
+	seen := make(map[string]struct{})
+	for _, line := range strings.Split(string(data[:end]), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		if _, done := w.processed[line]; done {
+			continue
+		}
+		if _, dup := seen[line]; dup {
+			continue
+		}
+		seen[line] = struct{}{}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// runBatch runs lines as a new run that appends to the output, and reports whether the run was
+// stopped early. The runner still skips processed lines after its own input handling (preprocess,
+// input_format), which may have changed them.
+func (w *inputWatcher) runBatch(batch int, lines []string) (bool, error) {
+	batchFile := filepath.Join(w.tempDir, fmt.Sprintf("batch_%d.txt", batch))
+	if err := os.WriteFile(batchFile, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return false, fmt.Errorf("failed to write watch batch: %w", err)
+	}
+	defer os.Remove(batchFile)
+
+	config := w.config
+	config.InputFile = batchFile
+	config.AppendOutput = true
+	config.SkipLines = w.processed
+	config.RequireOutput = false
+
+	runner, err := NewRunner(config)
+	if err != nil {
+		return false, err
+	}
+	if err := runner.Run(); err != nil {
+		return false, err
+	}
+	// Both forms count: the lines as read, and as the runner ran them
+	w.markProcessed(lines)
+	w.markProcessed(runner.inputLines)
+	return runner.cancelled(), nil
+}
+
+func (w *inputWatcher) markProcessed(lines []string) {
+	for _, line := range lines {
+		w.processed[line] = struct{}{}
+	}
+}